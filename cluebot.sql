@@ -5,22 +5,40 @@ CREATE TABLE IF NOT EXISTS `beaten`
     `article`   varchar(256) NOT NULL,
     `diff`      varchar(512) NOT NULL,
     `user`      varchar(256) NOT NULL,
+    `state`     varchar(32)  NOT NULL default 'beaten',
     PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 
 CREATE TABLE IF NOT EXISTS `vandalism`
 (
-    `id`        int(11)       NOT NULL auto_increment,
-    `timestamp` timestamp     NOT NULL default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP,
-    `user`      varchar(256)  NOT NULL,
-    `article`   varchar(256)  NOT NULL,
-    `heuristic` varchar(64)   NOT NULL,
-    `regex`     varchar(2048) default NULL,
-    `reason`    varchar(512)  NOT NULL,
-    `diff`      varchar(512)  NOT NULL,
-    `old_id`    int(11)       NOT NULL,
-    `new_id`    int(11)       NOT NULL,
-    `reverted`  tinyint(1)    NOT NULL,
+    `id`                    int(11)       NOT NULL auto_increment,
+    `timestamp`             timestamp     NOT NULL default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP,
+    `user`                  varchar(256)  NOT NULL,
+    `article`               varchar(256)  NOT NULL,
+    `heuristic`             varchar(64)   NOT NULL,
+    `regex`                 varchar(2048) default NULL,
+    `reason`                varchar(512)  NOT NULL,
+    `diff`                  varchar(512)  NOT NULL,
+    `old_id`                int(11)       NOT NULL,
+    `new_id`                int(11)       NOT NULL,
+    `reverted`              tinyint(1)    NOT NULL,
+    `revert_reason`         varchar(256)  NOT NULL default '',
+    `warning_template`      varchar(256)  default NULL,
+    `score`                 double        default NULL,
+    `core_version`          varchar(64)   default NULL,
+    `namespace`             varchar(64)   default NULL,
+    `comment`               varchar(512)  default NULL,
+    `creator`               varchar(256)  default NULL,
+    `prev_user`             varchar(256)  default NULL,
+    `page_made_time`        bigint        default NULL,
+    `num_recent_edits`      int           default NULL,
+    `num_recent_reversions` int           default NULL,
+    `user_edit_count`       int           default NULL,
+    `user_distinct_pages`   int           default NULL,
+    `user_warns`            int           default NULL,
+    `user_reg_time`         bigint        default NULL,
+    `new_timestamp`         int           default NULL,
+    `old_timestamp`         int           default NULL,
     PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 
@@ -31,3 +49,26 @@ CREATE TABLE IF NOT EXISTS `last_revert`
     `time`  int          NOT NULL,
     PRIMARY KEY (`title`, `user`)
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+-- Migrations for databases created before the columns were added to the definitions above (MariaDB)
+ALTER TABLE `vandalism`
+    ADD COLUMN IF NOT EXISTS `revert_reason`         varchar(256) NOT NULL default '',
+    ADD COLUMN IF NOT EXISTS `score`                 double       default NULL,
+    ADD COLUMN IF NOT EXISTS `core_version`          varchar(64)  default NULL,
+    ADD COLUMN IF NOT EXISTS `namespace`             varchar(64)  default NULL,
+    ADD COLUMN IF NOT EXISTS `comment`               varchar(512) default NULL,
+    ADD COLUMN IF NOT EXISTS `creator`               varchar(256) default NULL,
+    ADD COLUMN IF NOT EXISTS `prev_user`             varchar(256) default NULL,
+    ADD COLUMN IF NOT EXISTS `page_made_time`        bigint       default NULL,
+    ADD COLUMN IF NOT EXISTS `num_recent_edits`      int          default NULL,
+    ADD COLUMN IF NOT EXISTS `num_recent_reversions` int          default NULL,
+    ADD COLUMN IF NOT EXISTS `user_edit_count`       int          default NULL,
+    ADD COLUMN IF NOT EXISTS `user_distinct_pages`   int          default NULL,
+    ADD COLUMN IF NOT EXISTS `user_warns`            int          default NULL,
+    ADD COLUMN IF NOT EXISTS `user_reg_time`         bigint       default NULL,
+    ADD COLUMN IF NOT EXISTS `new_timestamp`         int          default NULL,
+    ADD COLUMN IF NOT EXISTS `old_timestamp`         int          default NULL,
+    ADD COLUMN IF NOT EXISTS `warning_template`      varchar(256) default NULL;
+
+ALTER TABLE `beaten`
+    ADD COLUMN IF NOT EXISTS `state` varchar(32) NOT NULL default 'beaten';
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/compare"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/database"
+	"github.com/cluebotng/botng/pkg/cbng/dataset"
+	"github.com/cluebotng/botng/pkg/cbng/feed"
+	"github.com/cluebotng/botng/pkg/cbng/processor"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+func runExportDataset(configuration *config.Configuration, args []string) int {
+	logger := logrus.WithField("function", "main.runExportDataset")
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: export-dataset <labelled revisions file> <output file>")
+		return 2
+	}
+
+	api := wikipedia.NewWikipediaApi(
+		configuration.Wikipedia.Credentials(),
+		configuration.Bot.EditOptions(),
+		configuration.Bot.ReadOnly,
+	)
+	db := database.NewDatabaseConnection(configuration)
+
+	result, err := dataset.ExportFile(logger, db, api, args[0], args[1])
+	if err != nil {
+		logger.Errorf("Failed to export dataset: %v", err)
+		return 1
+	}
+
+	failedIds := []int64{}
+	for revisionId := range result.Failed {
+		failedIds = append(failedIds, revisionId)
+	}
+	sort.Slice(failedIds, func(i, j int) bool { return failedIds[i] < failedIds[j] })
+
+	fmt.Printf("Exported %d edits to %s\n", result.Exported, args[1])
+	for _, revisionId := range failedIds {
+		fmt.Printf("Failed %d: %v\n", revisionId, result.Failed[revisionId])
+	}
+	if len(failedIds) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runExplain(configuration *config.Configuration, args []string) int {
+	logger := logrus.WithField("function", "main.runExplain")
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: explain <revision id>")
+		return 2
+	}
+
+	revisionId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid revision id: %s\n", args[0])
+		return 2
+	}
+
+	// Explain never writes, regardless of the configured mode
+	configuration.Bot.ReadOnly = true
+
+	var wg sync.WaitGroup
+	api := wikipedia.NewWikipediaApi(
+		configuration.Wikipedia.Credentials(),
+		configuration.Bot.EditOptions(),
+		configuration.Bot.ReadOnly,
+	)
+	configuration.LoadDynamic(&wg, api)
+	db := database.NewDatabaseConnection(configuration)
+
+	if err := processor.Explain(logger, configuration, db, api, revisionId, os.Stdout); err != nil {
+		logger.Errorf("Failed to explain %d: %v", revisionId, err)
+		return 1
+	}
+	return 0
+}
+
+func runCompare(configuration *config.Configuration, args []string) int {
+	logger := logrus.WithField("function", "main.runCompare")
+	if len(args) != 2 || (args[0] != "log" && args[0] != "table") {
+		fmt.Fprintln(os.Stderr, "usage: compare log <legacy decisions file> | compare table <first vandalism id>-<last vandalism id>")
+		return 2
+	}
+
+	// Comparisons are always dry-run
+	configuration.Bot.ReadOnly = true
+
+	var wg sync.WaitGroup
+	api := wikipedia.NewWikipediaApi(
+		configuration.Wikipedia.Credentials(),
+		configuration.Bot.EditOptions(),
+		configuration.Bot.ReadOnly,
+	)
+	configuration.LoadDynamic(&wg, api)
+	db := database.NewDatabaseConnection(configuration)
+
+	var legacyDecisions []compare.LegacyDecision
+	if args[0] == "log" {
+		file, err := os.Open(args[1])
+		if err != nil {
+			logger.Errorf("Failed to open legacy decisions: %v", err)
+			return 1
+		}
+		legacyDecisions, err = compare.ReadLegacyDecisionLog(file)
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Warnf("Failed to close legacy decisions: %v", closeErr)
+		}
+		if err != nil {
+			logger.Errorf("Failed to read legacy decisions: %v", err)
+			return 1
+		}
+	} else {
+		firstId, lastId, err := feed.ParseIdRange(args[1])
+		if err != nil {
+			logger.Errorf("Invalid vandalism id range: %v", err)
+			return 2
+		}
+		legacyDecisions, err = compare.LoadLegacyVandalismTable(logger, db, firstId, lastId)
+		if err != nil {
+			logger.Errorf("Failed to load legacy vandalism table: %v", err)
+			return 1
+		}
+	}
+
+	compare.Run(logger, configuration, db, api, legacyDecisions).Write(os.Stdout)
+	return 0
+}
+
+func runCommand(configuration *config.Configuration, args []string) int {
+	switch args[0] {
+	case "compare":
+		return runCompare(configuration, args[1:])
+	case "explain":
+		return runExplain(configuration, args[1:])
+	case "export-dataset":
+		return runExportDataset(configuration, args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+	return 2
+}
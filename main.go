@@ -10,8 +10,10 @@ import (
 	"github.com/cluebotng/botng/pkg/cbng/logging"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
 	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/policy"
 	"github.com/cluebotng/botng/pkg/cbng/processor"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
+	"github.com/cluebotng/botng/pkg/cbng/warning"
 	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -26,6 +28,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 )
@@ -64,6 +67,39 @@ func RunDatabasePurger(wg *sync.WaitGroup, db *database.DatabaseConnection) {
 	}
 }
 
+func collectChangeIds(api *wikipedia.WikipediaApi, changeId int64, changeIdsFile, changeIdsRange, changeIdsUser string, changeIdsUserLimit int) ([]int64, error) {
+	changeIds := []int64{}
+	if changeId > 0 {
+		changeIds = append(changeIds, changeId)
+	}
+
+	if changeIdsFile != "" {
+		ids, err := feed.ReadRevisionIdsFile(changeIdsFile)
+		if err != nil {
+			return nil, err
+		}
+		changeIds = append(changeIds, ids...)
+	}
+
+	if changeIdsRange != "" {
+		ids, err := feed.ParseRevisionRange(changeIdsRange)
+		if err != nil {
+			return nil, err
+		}
+		changeIds = append(changeIds, ids...)
+	}
+
+	if changeIdsUser != "" {
+		ids, err := feed.GetUserRevisionIds(api, changeIdsUser, changeIdsUserLimit)
+		if err != nil {
+			return nil, err
+		}
+		changeIds = append(changeIds, ids...)
+	}
+	// The sources can overlap, outcomes are per revision so each is only processed once
+	return feed.UniqueRevisionIds(changeIds), nil
+}
+
 func setupTracing(configuration *config.Configuration, debugMetrics bool) {
 	traceResourceOptions := []resource.Option{
 		resource.WithAttributes(semconv.ServiceNameKey.String("ClueBot NG")),
@@ -129,7 +165,13 @@ func main() {
 	var processors int
 	var sqlLoaders int
 	var httpLoaders int
+	var revisionBatchWait time.Duration
 	var changeId int64
+	var changeIdsFile string
+	var changeIdsRange string
+	var changeIdsUser string
+	var changeIdsUserLimit int
+	var batchConcurrency int
 
 	pflag.BoolVar(&debugLogging, "debug", false, "Should we log debug info")
 	pflag.BoolVar(&traceLogging, "trace", false, "Should we log trace info")
@@ -139,7 +181,13 @@ func main() {
 	pflag.IntVar(&processors, "processors", 5, "Number of processors to use")
 	pflag.IntVar(&sqlLoaders, "sql-loaders", 20, "Number of SQL loaders to use")
 	pflag.IntVar(&httpLoaders, "http-loaders", 20, "Number of HTTP loaders to use")
+	pflag.DurationVar(&revisionBatchWait, "revision-batch-wait", 100*time.Millisecond, "Maximum time to wait for other revisions to batch with")
 	pflag.Int64Var(&changeId, "process-id", 0, "Process a single ID, rather than feed")
+	pflag.StringVar(&changeIdsFile, "process-file", "", "Process IDs listed in a file (one per line), rather than feed")
+	pflag.StringVar(&changeIdsRange, "process-range", "", "Process an inclusive range of IDs (start-end), rather than feed")
+	pflag.StringVar(&changeIdsUser, "process-user", "", "Process a user's contributions, rather than feed")
+	pflag.IntVar(&changeIdsUserLimit, "process-user-limit", 100, "Number of contributions to process for --process-user")
+	pflag.IntVar(&batchConcurrency, "batch-concurrency", 5, "Number of IDs to resolve concurrently when not using the feed")
 	pflag.Parse()
 
 	if traceLogging {
@@ -166,6 +214,21 @@ func main() {
 	}
 
 	setupTracing(configuration, debugMetrics)
+
+	if _, err := policy.NewPolicy(configuration.Bot.RevertPolicy); err != nil {
+		logrus.Fatalf("invalid revert policy: %s", err)
+	}
+	if _, err := warning.NewSelector(configuration.Bot.WarningTemplates); err != nil {
+		logrus.Fatalf("invalid warning templates: %s", err)
+	}
+	if err := configuration.Wikipedia.Credentials().Validate(); err != nil {
+		logrus.Fatalf("invalid wikipedia credentials: %s", err)
+	}
+
+	if pflag.NArg() > 0 {
+		os.Exit(runCommand(configuration, pflag.Args()))
+	}
+
 	go logging.PruneOldLogFiles(&wg, configuration)
 
 	wg.Add(1)
@@ -178,9 +241,25 @@ func main() {
 		}
 	}()
 
+	batchMode := changeId > 0 || changeIdsFile != "" || changeIdsRange != "" || changeIdsUser != ""
+	if batchMode && !configuration.Bot.ReadOnly {
+		// Replayed edits are usually long since reverted or superseded, so never act on them
+		logrus.Warnf("Forcing read only mode when processing IDs")
+		configuration.Bot.ReadOnly = true
+	}
+	if batchMode && !ignoreReplicationDelay {
+		// Replayed revisions were replicated long ago and are received at their own (old) time, so would only expire
+		logrus.Infof("Skipping the replication check when processing IDs")
+		ignoreReplicationDelay = true
+	}
+	if batchMode && useIrcRelay {
+		logrus.Warnf("Disabling the IRC relay when processing IDs")
+		useIrcRelay = false
+	}
+
 	api := wikipedia.NewWikipediaApi(
-		configuration.Wikipedia.Username,
-		configuration.Wikipedia.Password,
+		configuration.Wikipedia.Credentials(),
+		configuration.Bot.EditOptions(),
 		configuration.Bot.ReadOnly,
 	)
 	configuration.LoadDynamic(&wg, api)
@@ -205,11 +284,21 @@ func main() {
 	wg.Add(1)
 	go RunMetricPoller(&wg, toPageMetadataLoader, toPageRecentEditCountLoader, toPageRecentRevertCountLoader, toUserEditCountLoader, toUserRegistrationLoader, toUserWarnsCountLoader, toUserDistinctPagesCountLoader, toRevisionLoader, toScoringProcessor, toRevertProcessor, r, db)
 
-	wg.Add(1)
-	go RunDatabasePurger(&wg, db)
+	if !batchMode {
+		wg.Add(1)
+		go RunDatabasePurger(&wg, db)
+	}
 
-	if changeId > 0 {
-		go feed.EmitSingleEdit(api, changeId, toReplicationWatcher)
+	var batchComplete chan *feed.BatchSummary
+	if batchMode {
+		changeIds, err := collectChangeIds(api, changeId, changeIdsFile, changeIdsRange, changeIdsUser, changeIdsUserLimit)
+		if err != nil {
+			logrus.Fatalf("failed to collect IDs to process: %s", err)
+		}
+		batchComplete = make(chan *feed.BatchSummary, 1)
+		go func() {
+			batchComplete <- feed.EmitBatchEdits(api, changeIds, batchConcurrency, toReplicationWatcher)
+		}()
 	} else {
 		wg.Add(1)
 		go feed.ConsumeHttpChangeEvents(&wg, configuration, toReplicationWatcher)
@@ -241,9 +330,10 @@ func main() {
 		go loader.LoadUserWarnsCount(&wg, db, r, toUserDistinctPagesCountLoader, toRevisionLoader)
 	}
 
+	revisionBatcher := wikipedia.NewRevisionBatcher(api, revisionBatchWait)
 	for i := 0; i < httpLoaders; i++ {
 		wg.Add(1)
-		go loader.LoadPageRevision(&wg, api, r, toRevisionLoader, toScoringProcessor)
+		go loader.LoadPageRevision(&wg, api, revisionBatcher, configuration.Bot.LogDiff, r, toRevisionLoader, toScoringProcessor)
 	}
 
 	for i := 0; i < processors; i++ {
@@ -251,7 +341,16 @@ func main() {
 		go processor.ProcessScoringChangeEvents(&wg, configuration, r, toScoringProcessor, toRevertProcessor)
 
 		wg.Add(1)
-		go processor.ProcessRevertChangeEvents(&wg, configuration, db, r, api, toRevertProcessor)
+		go processor.ProcessRevertChangeEvents(&wg, configuration, db, r, api, batchMode, toRevertProcessor)
+	}
+
+	if batchComplete != nil {
+		// Every change has left the pipeline, the remaining goroutines only serve the feed & metrics so exit directly
+		summary := <-batchComplete
+		if summary.Count(model.OutcomeFailed) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
 	wg.Wait()
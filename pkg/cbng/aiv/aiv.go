@@ -0,0 +1,81 @@
+package aiv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/helpers"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const Page = "Wikipedia:Administrator_intervention_against_vandalism/TB2"
+
+var ErrEvidencePresent = errors.New("evidence already reported")
+var ErrNotReported = errors.New("user is not reported")
+
+type Report struct {
+	Template string
+	User     string
+	Line     int
+}
+
+var reportRegex = regexp.MustCompile(`(?i)\{\{\s*(vandal|ipvandal)\s*\|\s*(?:1\s*=\s*)?([^|}]+?)\s*[|}]`)
+
+// NormaliseUser maps a username to the form MediaWiki stores it in, so reports can be compared exactly
+func NormaliseUser(user string) string {
+	user = strings.Join(strings.Fields(strings.ReplaceAll(user, "_", " ")), " ")
+	if ip := net.ParseIP(user); ip != nil {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+		return strings.ToUpper(ip.String())
+	}
+	first, size := utf8.DecodeRuneInString(user)
+	if first == utf8.RuneError {
+		return user
+	}
+	return string(unicode.ToUpper(first)) + user[size:]
+}
+
+// ParseReports returns every {{Vandal}}/{{IPvandal}} report on the page
+func ParseReports(text string) []Report {
+	reports := []Report{}
+	for lineNumber, line := range strings.Split(text, "\n") {
+		for _, match := range reportRegex.FindAllStringSubmatch(line, -1) {
+			reports = append(reports, Report{Template: match[1], User: NormaliseUser(match[2]), Line: lineNumber})
+		}
+	}
+	return reports
+}
+
+func FindReport(text, user string) *Report {
+	user = NormaliseUser(user)
+	for _, report := range ParseReports(text) {
+		if report.User == user {
+			return &report
+		}
+	}
+	return nil
+}
+
+func NewReport(user, evidence string) string {
+	return fmt.Sprintf("* {{%s|%s}} - %s (Automated) ~~~~", helpers.AivUserVandalType(user), user, evidence)
+}
+
+// AddEvidence appends further evidence to the end of an existing report line
+func AddEvidence(text, user, diffUrl, evidence string) (string, error) {
+	report := FindReport(text, user)
+	if report == nil {
+		return "", ErrNotReported
+	}
+
+	lines := strings.Split(text, "\n")
+	if strings.Contains(lines[report.Line], diffUrl) {
+		return "", ErrEvidencePresent
+	}
+	lines[report.Line] = fmt.Sprintf("%s; additionally %s (Automated) ~~~~", lines[report.Line], evidence)
+	return strings.Join(lines, "\n"), nil
+}
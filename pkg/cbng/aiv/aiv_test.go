@@ -0,0 +1,74 @@
+package aiv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFindReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		user     string
+		reported bool
+	}{
+		{"exact match", "* {{Vandal|Foo}} - vandalism", "Foo", true},
+		{"prefix of another user", "* {{Vandal|Foobar}} - vandalism", "Foo", false},
+		{"another user is a prefix", "* {{Vandal|Foo}} - vandalism", "Foobar", false},
+		{"underscores in report", "* {{Vandal|Foo_Bar}} - vandalism", "Foo Bar", true},
+		{"underscores in user", "* {{Vandal|Foo Bar}} - vandalism", "Foo_Bar", true},
+		{"lower case first letter", "* {{Vandal|foo}} - vandalism", "Foo", true},
+		{"case beyond the first letter", "* {{Vandal|FOO}} - vandalism", "Foo", false},
+		{"numbered param", "* {{Vandal|1=Foo}} - vandalism", "Foo", true},
+		{"numbered param with spaces", "* {{vandal | 1 = Foo }} - vandalism", "Foo", true},
+		{"ipvandal", "* {{IPvandal|192.0.2.1}} - vandalism", "192.0.2.1", true},
+		{"ipv6 case", "* {{IPvandal|2001:DB8::1}} - vandalism", "2001:db8::1", true},
+		{"ipv6 expanded", "* {{IPvandal|2001:0db8:0000:0000:0000:0000:0000:0001}} - vandalism", "2001:db8::1", true},
+		{"other ip", "* {{IPvandal|192.0.2.10}} - vandalism", "192.0.2.1", false},
+		{"not a report template", "* {{User|Foo}} - vandalism", "Foo", false},
+		{"second line", "== User-reported ==\n* {{Vandal|Bar}}\n* {{Vandal|Foo}}", "Foo", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := FindReport(tt.text, tt.user)
+			if tt.reported && report == nil {
+				t.Errorf("expected %s to be reported in %q", tt.user, tt.text)
+			}
+			if !tt.reported && report != nil {
+				t.Errorf("expected %s not to be reported, matched %+v", tt.user, report)
+			}
+		})
+	}
+}
+
+func TestAddEvidence(t *testing.T) {
+	text := "== Bot-reported ==\n* {{Vandal|Foo}} - [https://example.org/diff/1 changed] (Automated) ~~~~\n* {{Vandal|Foobar}} - other"
+
+	updated, err := AddEvidence(text, "Foo", "https://example.org/diff/2", "[https://example.org/diff/2 changed]")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(updated, "\n")
+	if !strings.HasSuffix(lines[1], "; additionally [https://example.org/diff/2 changed] (Automated) ~~~~") {
+		t.Errorf("expected evidence on the report line, got %q", lines[1])
+	}
+	if lines[2] != "* {{Vandal|Foobar}} - other" {
+		t.Errorf("expected other reports to be untouched, got %q", lines[2])
+	}
+}
+
+func TestAddEvidenceAlreadyPresent(t *testing.T) {
+	text := "* {{Vandal|Foo}} - [https://example.org/diff/1 changed] (Automated) ~~~~"
+
+	if _, err := AddEvidence(text, "Foo", "https://example.org/diff/1", "[https://example.org/diff/1 changed]"); !errors.Is(err, ErrEvidencePresent) {
+		t.Errorf("expected the evidence to already be present, got %v", err)
+	}
+}
+
+func TestAddEvidenceNotReported(t *testing.T) {
+	if _, err := AddEvidence("* {{Vandal|Foobar}} - other", "Foo", "https://example.org/diff/1", "evidence"); !errors.Is(err, ErrNotReported) {
+		t.Errorf("expected the user not to be reported, got %v", err)
+	}
+}
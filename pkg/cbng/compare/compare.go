@@ -0,0 +1,213 @@
+package compare
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/database"
+	"github.com/cluebotng/botng/pkg/cbng/feed"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/processor"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+type LegacyDecision struct {
+	RevisionId int64              `json:"revid"`
+	Reverted   bool               `json:"reverted"`
+	Reason     string             `json:"reason"`
+	Score      *float64           `json:"score,omitempty"`
+	Features   map[string]float64 `json:"features,omitempty"`
+}
+
+type FeatureDifference struct {
+	Feature string
+	Legacy  float64
+	Ours    float64
+}
+
+type Comparison struct {
+	Legacy             LegacyDecision
+	Ours               *processor.ExplainReport
+	Error              error
+	Reverted           bool
+	Agreed             bool
+	FeatureDifferences []FeatureDifference
+}
+
+type Report struct {
+	Comparisons []Comparison
+	Agreed      int
+	Disagreed   int
+	Failed      int
+}
+
+var legacyScoreRegex = regexp.MustCompile(`ANN scored at ([0-9.]+)`)
+
+// ReadLegacyDecisionLog parses one JSON encoded LegacyDecision per line
+func ReadLegacyDecisionLog(r io.Reader) ([]LegacyDecision, error) {
+	decisions := []LegacyDecision{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		decision := LegacyDecision{}
+		if err := json.Unmarshal(scanner.Bytes(), &decision); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, scanner.Err()
+}
+
+func LoadLegacyVandalismTable(l *logrus.Entry, db *database.DatabaseConnection, firstId, lastId int64) ([]LegacyDecision, error) {
+	rows, err := db.ClueBot.GetVandalismDecisions(l, context.Background(), firstId, lastId)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := []LegacyDecision{}
+	for _, row := range rows {
+		decision := LegacyDecision{RevisionId: row.RevisionId, Reverted: row.Reverted, Reason: row.Reason, Features: row.Features}
+		if score, ok := row.Features["score"]; ok {
+			decision.Score = &score
+		} else if m := legacyScoreRegex.FindStringSubmatch(row.Reason); m != nil {
+			if score, err := strconv.ParseFloat(m[1], 64); err == nil {
+				decision.Score = &score
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, nil
+}
+
+func changeFeatures(change *model.ProcessEvent) map[string]float64 {
+	return map[string]float64{
+		"score":                 change.VandalismScore,
+		"user_edit_count":       float64(change.User.EditCount),
+		"user_distinct_pages":   float64(change.User.DistinctPages),
+		"user_warns":            float64(change.User.Warns),
+		"user_reg_time":         float64(change.User.RegistrationTime),
+		"page_made_time":        float64(change.Common.PageMadeTime),
+		"num_recent_edits":      float64(change.Common.NumRecentEdits),
+		"num_recent_reversions": float64(change.Common.NumRecentRevisions),
+		"current_timestamp":     float64(change.Current.Timestamp),
+		"previous_timestamp":    float64(change.Previous.Timestamp),
+	}
+}
+
+func featureDifferences(legacy LegacyDecision, change *model.ProcessEvent) []FeatureDifference {
+	legacyFeatures := map[string]float64{}
+	for name, value := range legacy.Features {
+		legacyFeatures[name] = value
+	}
+	if legacy.Score != nil {
+		legacyFeatures["score"] = *legacy.Score
+	}
+
+	ours := changeFeatures(change)
+	differences := []FeatureDifference{}
+	for name, legacyValue := range legacyFeatures {
+		ourValue, ok := ours[name]
+		if !ok {
+			continue
+		}
+		if math.Abs(legacyValue-ourValue) > 0.000001 {
+			differences = append(differences, FeatureDifference{Feature: name, Legacy: legacyValue, Ours: ourValue})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool { return differences[i].Feature < differences[j].Feature })
+	return differences
+}
+
+func Run(l *logrus.Entry, configuration *config.Configuration, db *database.DatabaseConnection, api *wikipedia.WikipediaApi, legacyDecisions []LegacyDecision) *Report {
+	logger := l.WithField("function", "compare.Run")
+	ctx, span := metrics.OtelTracer.Start(context.Background(), "compare.Run")
+	defer span.End()
+
+	report := Report{}
+	for _, legacy := range legacyDecisions {
+		comparison := Comparison{Legacy: legacy}
+
+		change, err := feed.NewChangeFromRevisionId(logger, api, legacy.RevisionId)
+		if err == nil {
+			comparison.Ours, err = processor.EvaluateChange(change.Logger, ctx, configuration, db, api, change)
+		}
+		if err != nil {
+			logger.Warnf("Failed to evaluate %d: %v", legacy.RevisionId, err)
+			comparison.Error = err
+			report.Failed++
+			report.Comparisons = append(report.Comparisons, comparison)
+			continue
+		}
+
+		comparison.Reverted = comparison.Ours.ScoringError == nil &&
+			comparison.Ours.IsVandalism &&
+			!comparison.Ours.Whitelisted &&
+			comparison.Ours.ShouldRevert
+		comparison.Agreed = comparison.Reverted == legacy.Reverted
+		comparison.FeatureDifferences = featureDifferences(legacy, change)
+
+		if comparison.Agreed {
+			report.Agreed++
+		} else {
+			report.Disagreed++
+		}
+		report.Comparisons = append(report.Comparisons, comparison)
+	}
+	return &report
+}
+
+func (r *Report) Write(w io.Writer) {
+	decision := func(reverted bool) string {
+		if reverted {
+			return "reverted"
+		}
+		return "not reverted"
+	}
+
+	compared := r.Agreed + r.Disagreed
+	agreement := 0.0
+	if compared > 0 {
+		agreement = float64(r.Agreed) / float64(compared) * 100
+	}
+	_, _ = fmt.Fprintf(w, "Compared %d revisions: %d agreed, %d disagreed (%.2f%% agreement), %d failed\n",
+		compared, r.Agreed, r.Disagreed, agreement, r.Failed)
+	_, _ = fmt.Fprintln(w, "Note: revert rules are evaluated against the current state (last revert times, Run & TFA pages), "+
+		"not the state when the legacy decision was made")
+
+	for _, comparison := range r.Comparisons {
+		if comparison.Error != nil {
+			_, _ = fmt.Fprintf(w, "\n%d: failed to evaluate: %v\n", comparison.Legacy.RevisionId, comparison.Error)
+			continue
+		}
+		if comparison.Agreed && len(comparison.FeatureDifferences) == 0 {
+			continue
+		}
+
+		state := "agreed"
+		if !comparison.Agreed {
+			state = "DISAGREED"
+		}
+		_, _ = fmt.Fprintf(w, "\n%d (%s) %s\n", comparison.Legacy.RevisionId, comparison.Ours.Change.GetDiffUrl(), state)
+		_, _ = fmt.Fprintf(w, "  legacy: %s (%s)\n", decision(comparison.Legacy.Reverted), comparison.Legacy.Reason)
+		_, _ = fmt.Fprintf(w, "  ours:   %s (%s)\n", decision(comparison.Reverted), comparison.Ours.Decision)
+		for _, difference := range comparison.FeatureDifferences {
+			_, _ = fmt.Fprintf(w, "  %-22s legacy=%v ours=%v\n", difference.Feature, difference.Legacy, difference.Ours)
+		}
+	}
+}
@@ -55,8 +55,10 @@ func (a *AngryOptInConfigurationInstance) reload() {
 	ctx, span := metrics.OtelTracer.Start(context.Background(), "AngryOptInConfigurationReload")
 	defer span.End()
 
-	revision := a.w.GetPage(logger, ctx, a.GetPageName())
-	if revision != nil {
+	revision, err := a.w.GetPage(logger, ctx, a.GetPageName())
+	if err != nil {
+		logger.Errorf("Failed to fetch %s: %v", a.GetPageName(), err)
+	} else {
 		pages := []string{}
 		for _, line := range strings.Split(revision.Data, "\n") {
 			m := regexp.MustCompile(`^\* \[\[(.+)\]\] \-`).FindAllStringSubmatch(line, 1)
@@ -6,24 +6,37 @@ import (
 	"github.com/spf13/viper"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var ReleaseTag = "development"
 var RecentRevertThreshold = int64(86400)
-var RecentChangeWindow = int64(14 * 86400)
 
 type BotConfiguration struct {
-	Owner    string
-	Friends  []string
-	Run      bool
-	Angry    bool
-	ReadOnly bool
+	Owner              string
+	Friends            []string
+	Run                bool
+	Angry              bool
+	ReadOnly           bool
+	RevertPolicy       []RevertRuleConfiguration
+	OnWikiRevertPolicy bool
+	EditCountThreshold int64
+	WarnRatioThreshold float64
+	WarningWindow      time.Duration
+	WarningTemplates   []WarningTemplateConfiguration
+	Tags               []string
+	MarkBot            bool
+	Watchlist          string
+	LogDiff            bool
 }
 
 type WikipediaConfiguration struct {
-	Username string
-	Password string
-	Host     string
+	Username         string
+	Password         string
+	Host             string
+	Auth             string
+	OAuthAccessToken string
 }
 
 type SqlConfiguration struct {
@@ -45,6 +58,15 @@ type DynamicConfiguration struct {
 	AngryOptinPages     []string
 	NamespaceOptIn      []string
 	Run                 bool
+	// Replaced as a whole on reload, so readers never see the rules of one reload paired with another's generation
+	RevertPolicy atomic.Pointer[OnWikiRevertPolicy]
+}
+
+// OnWikiRevertPolicy is the parsed on-wiki policy, the generation is incremented each time the rules change so the
+// built policy is only rebuilt after a reload
+type OnWikiRevertPolicy struct {
+	Rules      []RevertRuleConfiguration
+	Generation int64
 }
 
 type IrcRelayChannelConfiguration struct {
@@ -62,8 +84,9 @@ type IrcConfiguration struct {
 }
 
 type CoreConfiguration struct {
-	Host string
-	Port int
+	Host    string
+	Port    int
+	Version string
 }
 
 type HoneyConfiguration struct {
@@ -82,6 +105,7 @@ type Instances struct {
 	NamespaceOptIn          *NamespaceOptInInstance
 	Run                     *RunInstance
 	TFA                     *TFAInstance
+	RevertPolicy            *RevertPolicyInstance
 }
 
 type Configuration struct {
@@ -118,14 +142,25 @@ func NewConfiguration() *Configuration {
 				"ClueBot",
 				"DASHBotAV",
 			},
-			Run:      envVarWithDefault("CBNG_CFG_RUN", "true") == "true",
-			Angry:    envVarWithDefault("CBNG_CFG_ANGRY", "false") == "true",
-			ReadOnly: envVarWithDefault("CBNG_CFG_READ_ONLY", "true") == "true",
+			Run:                envVarWithDefault("CBNG_CFG_RUN", "true") == "true",
+			Angry:              envVarWithDefault("CBNG_CFG_ANGRY", "false") == "true",
+			ReadOnly:           envVarWithDefault("CBNG_CFG_READ_ONLY", "true") == "true",
+			OnWikiRevertPolicy: envVarWithDefault("CBNG_CFG_ON_WIKI_REVERT_POLICY", "false") == "true",
+			EditCountThreshold: 50,
+			WarnRatioThreshold: 0.1,
+			WarningWindow:      2 * 24 * time.Hour,
+			WarningTemplates:   DefaultWarningTemplates(),
+			Tags:               []string{},
+			MarkBot:            false,
+			Watchlist:          "nochange",
+			LogDiff:            envVarWithDefault("CBNG_CFG_LOG_DIFF", "false") == "true",
 		},
 		Wikipedia: WikipediaConfiguration{
-			Host:     "en.wikipedia.org",
-			Username: "ClueBot_NG",
-			Password: envVarWithDefault("CBNG_WIKIPEDIA_PASSWORD", ""),
+			Host:             "en.wikipedia.org",
+			Username:         "ClueBot_NG",
+			Password:         envVarWithDefault("CBNG_WIKIPEDIA_PASSWORD", ""),
+			Auth:             envVarWithDefault("CBNG_WIKIPEDIA_AUTH", wikipedia.AuthPassword),
+			OAuthAccessToken: envVarWithDefault("CBNG_WIKIPEDIA_OAUTH_ACCESS_TOKEN", ""),
 		},
 		Irc: IrcConfiguration{
 			Server:   "irc.libera.chat",
@@ -156,8 +191,9 @@ func NewConfiguration() *Configuration {
 			},
 		},
 		Core: CoreConfiguration{
-			Host: "core",
-			Port: 3565,
+			Host:    "core",
+			Port:    3565,
+			Version: envVarWithDefault("CBNG_CORE_VERSION", ""),
 		},
 		Honey: HoneyConfiguration{
 			Key:        envVarWithDefault("CBNG_HONEY_KEY", ""),
@@ -182,6 +218,11 @@ func NewConfiguration() *Configuration {
 	if err != nil {
 		logger.Fatalf("unable to decode into struct, %v", err)
 	}
+
+	// The default policy describes the configured ratio, so is only built once the configuration is loaded
+	if !viper.IsSet("bot.revertpolicy") {
+		configuration.Bot.RevertPolicy = DefaultRevertPolicy(configuration.Bot.WarnRatioThreshold)
+	}
 	return &configuration
 }
 
@@ -191,4 +232,24 @@ func (c *Configuration) LoadDynamic(wg *sync.WaitGroup, wikipediaApi *wikipedia.
 	c.Instances.HuggleConfiguration = NewHuggleConfiguration(c, wikipediaApi, wg)
 	c.Instances.NamespaceOptIn = NewNamespaceOptIn(c, wikipediaApi, wg)
 	c.Instances.Run = NewRun(c, wikipediaApi, wg)
+	if c.Bot.OnWikiRevertPolicy {
+		c.Instances.RevertPolicy = NewRevertPolicy(c, wikipediaApi, wg)
+	}
+}
+
+func (w WikipediaConfiguration) Credentials() wikipedia.Credentials {
+	return wikipedia.Credentials{
+		Method:      w.Auth,
+		Username:    w.Username,
+		Password:    w.Password,
+		AccessToken: w.OAuthAccessToken,
+	}
+}
+
+func (b BotConfiguration) EditOptions() wikipedia.EditOptions {
+	return wikipedia.EditOptions{
+		Tags:      b.Tags,
+		MarkBot:   b.MarkBot,
+		Watchlist: b.Watchlist,
+	}
 }
@@ -55,8 +55,10 @@ func (n *NamespaceOptInInstance) reload() {
 	ctx, span := metrics.OtelTracer.Start(context.Background(), "NamespaceConfigurationReload")
 	defer span.End()
 
-	revision := n.w.GetPage(logger, ctx, n.GetPageName())
-	if revision != nil {
+	revision, err := n.w.GetPage(logger, ctx, n.GetPageName())
+	if err != nil {
+		logger.Errorf("Failed to fetch %s: %v", n.GetPageName(), err)
+	} else {
 		pages := []string{}
 		for _, line := range strings.Split(revision.Data, "\n") {
 			m := regexp.MustCompile(`^\* \[\[(.+)\]\] \-`).FindAllStringSubmatch(line, 1)
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RevertRuleConfiguration struct {
+	Name      string            `json:"name"`
+	Condition string            `json:"condition"`
+	Params    map[string]string `json:"params"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason"`
+	Metric    string            `json:"metric"`
+}
+
+// DefaultRevertPolicy mirrors the legacy bot, the warn ratio is only used to describe the edit count rule
+func DefaultRevertPolicy(warnRatioThreshold float64) []RevertRuleConfiguration {
+	return []RevertRuleConfiguration{
+		{Name: "local_config", Condition: "local_run_disabled", Outcome: "fail", Reason: "Run Disabled"},
+		{Name: "remote_config", Condition: "remote_run_disabled", Outcome: "fail", Reason: "Run Disabled"},
+		{Name: "self_edit", Condition: "self_edit", Outcome: "fail", Reason: "User is myself"},
+		{Name: "protected", Condition: "protected", Outcome: "skip", Reason: "Page is protected"},
+		{Name: "angry", Condition: "angry_mode", Outcome: "revert", Reason: "Angry-reverting in angry mode"},
+		{Name: "exclusion", Condition: "exclusion", Outcome: "skip", Reason: "Exclusion compliance"},
+		{Name: "common_creator", Condition: "user_is_creator", Outcome: "skip", Reason: "User is creator"},
+		{Name: "high_edit_count", Condition: "edit_count_warn_ratio_below", Outcome: "skip", Reason: "User has edit count"},
+		{Name: "edit_count_warn_perc", Condition: "edit_count_above", Outcome: "revert", Reason: fmt.Sprintf("User has edit count, but warns > %s%%", strconv.FormatFloat(warnRatioThreshold*100, 'f', -1, 64))},
+		{Name: "angry_tfa", Condition: "tfa", Outcome: "revert", Reason: "Angry-reverting on TFA"},
+		{Name: "angry_opt_in", Condition: "angry_opt_in", Outcome: "revert", Reason: "Angry-reverting on angry-optin"},
+		{Name: "recent_revert", Condition: "recent_revert", Outcome: "skip", Reason: "Reverted before"},
+		{Name: "fallback", Condition: "always", Outcome: "revert", Reason: "Default Revert"},
+	}
+}
+
+// parseRevertPolicy decodes the on-wiki JSON list of rules, an empty list means the local policy is used
+func parseRevertPolicy(data string) ([]RevertRuleConfiguration, error) {
+	rules := []RevertRuleConfiguration{}
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+type RevertPolicyInstance struct {
+	c          *Configuration
+	w          *wikipedia.WikipediaApi
+	reloadChan chan bool
+}
+
+func (r *RevertPolicyInstance) TriggerReload() {
+	r.reloadChan <- true
+}
+
+func (r *RevertPolicyInstance) GetPageName() string {
+	return fmt.Sprintf("User:%s/RevertPolicy.json", strings.ReplaceAll(r.c.Wikipedia.Username, " ", "_"))
+}
+
+func (r *RevertPolicyInstance) start(wg *sync.WaitGroup) {
+	logger := logrus.WithField("function", "config.RevertPolicyInstance.start")
+	r.reload()
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		timer := time.NewTicker(time.Hour)
+		for {
+			select {
+			case <-timer.C:
+				logger.Debugf("Reloading From Timer")
+				r.reload()
+			case <-r.reloadChan:
+				logger.Debugf("Reloading From Trigger")
+				r.reload()
+			}
+		}
+	}(wg)
+}
+
+func (r *RevertPolicyInstance) reload() {
+	logger := logrus.WithField("function", "config.RevertPolicyInstance.reload")
+
+	ctx, span := metrics.OtelTracer.Start(context.Background(), "RevertPolicyConfigurationReload")
+	defer span.End()
+
+	revision, err := r.w.GetPage(logger, ctx, r.GetPageName())
+	if err != nil {
+		logger.Errorf("Failed to fetch %s: %v", r.GetPageName(), err)
+	} else {
+		rules, err := parseRevertPolicy(revision.Data)
+		if err != nil {
+			logger.Errorf("Failed to decode revert policy: %v", err)
+			return
+		}
+
+		current := r.c.Dynamic.RevertPolicy.Load()
+		if current == nil || !reflect.DeepEqual(current.Rules, rules) {
+			logger.Infof("Updating revert policy to: %+v", rules)
+			generation := int64(1)
+			if current != nil {
+				generation = current.Generation + 1
+			}
+			r.c.Dynamic.RevertPolicy.Store(&OnWikiRevertPolicy{Rules: rules, Generation: generation})
+		}
+	}
+}
+
+func NewRevertPolicy(c *Configuration, w *wikipedia.WikipediaApi, wg *sync.WaitGroup) *RevertPolicyInstance {
+	i := RevertPolicyInstance{
+		c:          c,
+		w:          w,
+		reloadChan: make(chan bool),
+	}
+	i.start(wg)
+	return &i
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParseRevertPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		rules   int
+		invalid bool
+	}{
+		{"rules", `[{"name": "fallback", "condition": "always", "outcome": "revert", "reason": "Default Revert"}]`, 1, false},
+		{"params", `[{"name": "ns", "condition": "namespace", "params": {"ids": "0,1"}, "outcome": "skip"}]`, 1, false},
+		{"empty list", `[]`, 0, false},
+		{"not json", `{{User:ClueBot NG/RevertPolicy}}`, 0, true},
+		{"object rather than list", `{"name": "fallback"}`, 0, true},
+		{"truncated", `[{"name": "fallback", "condition": "always"`, 0, true},
+		{"wrong param type", `[{"name": "ns", "condition": "namespace", "params": {"ids": [0, 1]}}]`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRevertPolicy(tt.data)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(rules) != tt.rules {
+				t.Errorf("expected %d rules, got %+v", tt.rules, rules)
+			}
+		})
+	}
+}
+
+func TestDefaultRevertPolicyDescribesWarnRatio(t *testing.T) {
+	tests := []struct {
+		ratio  float64
+		reason string
+	}{
+		{0.1, "User has edit count, but warns > 10%"},
+		{0.25, "User has edit count, but warns > 25%"},
+		{0.05, "User has edit count, but warns > 5%"},
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, rule := range DefaultRevertPolicy(tt.ratio) {
+			if rule.Name == "edit_count_warn_perc" {
+				found = true
+				if rule.Reason != tt.reason {
+					t.Errorf("ratio %v: expected reason %q, got %q", tt.ratio, tt.reason, rule.Reason)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("default policy has no edit_count_warn_perc rule")
+		}
+	}
+}
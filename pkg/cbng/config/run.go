@@ -53,8 +53,10 @@ func (r *RunInstance) reload() {
 	ctx, span := metrics.OtelTracer.Start(context.Background(), "RunConfigurationReload")
 	defer span.End()
 
-	revision := r.w.GetPage(logger, ctx, r.GetPageName())
-	if revision != nil {
+	revision, err := r.w.GetPage(logger, ctx, r.GetPageName())
+	if err != nil {
+		logger.Errorf("Failed to fetch %s: %v", r.GetPageName(), err)
+	} else {
 		shouldRun := false
 		if strings.Contains(strings.ToLower(revision.Data), "true") {
 			shouldRun = true
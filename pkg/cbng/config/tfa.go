@@ -53,8 +53,10 @@ func (t *TFAInstance) reload() {
 	ctx, span := metrics.OtelTracer.Start(context.Background(), "TFAConfigurationReload")
 	defer span.End()
 
-	revision := t.w.GetPage(logger, ctx, t.GetPageName())
-	if revision != nil {
+	revision, err := t.w.GetPage(logger, ctx, t.GetPageName())
+	if err != nil {
+		logger.Errorf("Failed to fetch %s: %v", t.GetPageName(), err)
+	} else {
 		article := regexp.MustCompile(`{{TFAFULL\|([^}]+)}}`).FindAllStringSubmatch(revision.Data, 1)
 		if len(article) != 1 {
 			logger.Errorf("Failed to find TFA: '%v'", revision.Data)
@@ -0,0 +1,30 @@
+package config
+
+type WarningTemplateConfiguration struct {
+	Name      string            `json:"name"`
+	Condition string            `json:"condition"`
+	Params    map[string]string `json:"params"`
+	Template  string            `json:"template"`
+	Arguments []string          `json:"arguments"`
+}
+
+// ClueBotWarningArguments are passed to the bot's own warning template, which picks the next level itself
+var ClueBotWarningArguments = []string{
+	"1={level}",
+	"2={page}",
+	"3={report} <!{{subst:ns:0}}-- MySQL ID: {id} --{{subst:ns:0}}>",
+	"4={id}",
+}
+
+// UserWarningArguments are passed to the standard uw-* templates, which are named by level
+var UserWarningArguments = []string{
+	"1={page}",
+	"2=If this is a mistake, [[User:{bot}/FalsePositives|please report it]]. <!{{subst:ns:0}}-- MySQL ID: {id} --{{subst:ns:0}}>",
+}
+
+// DefaultWarningTemplates always use the on-wiki warning template
+func DefaultWarningTemplates() []WarningTemplateConfiguration {
+	return []WarningTemplateConfiguration{
+		{Name: "default", Condition: "always", Template: "User:{bot}/Warnings/Warning", Arguments: ClueBotWarningArguments},
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"github.com/cluebotng/botng/pkg/cbng/config"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
+	"strings"
 	"time"
 )
 
@@ -38,7 +40,11 @@ func (ci *CluebotInstance) getDatabaseConnection() (*sql.DB, error) {
 	return db, nil
 }
 
-func (ci *CluebotInstance) GenerateVandalismId(logger *logrus.Entry, ctx context.Context, user, title, reason, diffUrl string, previousId, currentId int64) (int64, error) {
+func nullIfEmptyString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func (ci *CluebotInstance) GenerateVandalismId(logger *logrus.Entry, ctx context.Context, core config.CoreConfiguration, change *model.ProcessEvent, reason string) (int64, error) {
 	_, span := metrics.OtelTracer.Start(ctx, "cluebot.GenerateVandalismId")
 	defer span.End()
 
@@ -54,7 +60,19 @@ func (ci *CluebotInstance) GenerateVandalismId(logger *logrus.Entry, ctx context
 		}
 	}()
 
-	res, err := db.Exec("INSERT INTO `vandalism` (`id`,`user`,`article`,`heuristic`,`reason`,`diff`,`old_id`,`new_id`,`reverted`) VALUES (NULL, ?, ?, '', ?, ?, ?, ?, 0)", user, title, reason, diffUrl, previousId, currentId)
+	res, err := db.Exec("INSERT INTO `vandalism` "+
+		"(`id`,`user`,`article`,`heuristic`,`reason`,`diff`,`old_id`,`new_id`,`reverted`,"+
+		"`score`,`core_version`,`namespace`,`comment`,`creator`,`prev_user`,"+
+		"`page_made_time`,`num_recent_edits`,`num_recent_reversions`,"+
+		"`user_edit_count`,`user_distinct_pages`,`user_warns`,`user_reg_time`,"+
+		"`new_timestamp`,`old_timestamp`) "+
+		"VALUES (NULL, ?, ?, '', ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		change.User.Username, change.Common.Title, reason, change.GetDiffUrl(), change.Previous.Id, change.Current.Id,
+		change.VandalismScore, nullIfEmptyString(core.Version),
+		change.Common.Namespace, change.Comment, change.Common.Creator, change.Previous.Username,
+		change.Common.PageMadeTime, change.Common.NumRecentEdits, change.Common.NumRecentRevisions,
+		change.User.EditCount, change.User.DistinctPages, change.User.Warns, change.User.RegistrationTime,
+		change.Current.Timestamp, change.Previous.Timestamp)
 	if err != nil {
 		logger.Errorf("Error running query: %v", err)
 		span.SetStatus(codes.Error, err.Error())
@@ -72,6 +90,164 @@ func (ci *CluebotInstance) GenerateVandalismId(logger *logrus.Entry, ctx context
 	return vandalismId, nil
 }
 
+func (ci *CluebotInstance) SaveVandalismRevertReason(l *logrus.Entry, ctx context.Context, vandalismId int64, revertReason string) error {
+	logger := l.WithFields(logrus.Fields{
+		"function": "database.cluebot.SaveVandalismRevertReason",
+		"args": map[string]interface{}{
+			"vandalismId":  vandalismId,
+			"revertReason": revertReason,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "cluebot.SaveVandalismRevertReason")
+	defer span.End()
+
+	db, err := ci.getDatabaseConnection()
+	if err != nil {
+		logger.Errorf("Error connecting to db: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logrus.Warnf("Failed to close database connection: %v", err)
+		}
+	}()
+
+	if _, err := db.Exec("UPDATE `vandalism` SET `revert_reason` = ? WHERE `id` = ?", revertReason, vandalismId); err != nil {
+		logger.Errorf("Error running query: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	logger.Debugf("Updated revert reason")
+	return nil
+}
+
+func (ci *CluebotInstance) SaveVandalismWarningTemplate(l *logrus.Entry, ctx context.Context, vandalismId int64, warningTemplate string) error {
+	logger := l.WithFields(logrus.Fields{
+		"function": "database.cluebot.SaveVandalismWarningTemplate",
+		"args": map[string]interface{}{
+			"vandalismId":     vandalismId,
+			"warningTemplate": warningTemplate,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "cluebot.SaveVandalismWarningTemplate")
+	defer span.End()
+
+	db, err := ci.getDatabaseConnection()
+	if err != nil {
+		logger.Errorf("Error connecting to db: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logrus.Warnf("Failed to close database connection: %v", err)
+		}
+	}()
+
+	if _, err := db.Exec("UPDATE `vandalism` SET `warning_template` = ? WHERE `id` = ?", warningTemplate, vandalismId); err != nil {
+		logger.Errorf("Error running query: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	logger.Debugf("Updated warning template")
+	return nil
+}
+
+type VandalismDecision struct {
+	Id         int64
+	RevisionId int64
+	User       string
+	Title      string
+	Reason     string
+	Reverted   bool
+	Features   map[string]float64
+}
+
+// Stored feature columns, keyed by the name used when comparing against a fresh evaluation
+var vandalismFeatureColumns = []struct {
+	column  string
+	feature string
+}{
+	{"score", "score"},
+	{"user_edit_count", "user_edit_count"},
+	{"user_distinct_pages", "user_distinct_pages"},
+	{"user_warns", "user_warns"},
+	{"user_reg_time", "user_reg_time"},
+	{"page_made_time", "page_made_time"},
+	{"num_recent_edits", "num_recent_edits"},
+	{"num_recent_reversions", "num_recent_reversions"},
+	{"new_timestamp", "current_timestamp"},
+	{"old_timestamp", "previous_timestamp"},
+}
+
+func (ci *CluebotInstance) GetVandalismDecisions(l *logrus.Entry, ctx context.Context, firstId, lastId int64) ([]VandalismDecision, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "database.cluebot.GetVandalismDecisions",
+		"args": map[string]interface{}{
+			"firstId": firstId,
+			"lastId":  lastId,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "cluebot.GetVandalismDecisions")
+	defer span.End()
+
+	db, err := ci.getDatabaseConnection()
+	if err != nil {
+		logger.Errorf("Error connecting to db: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logrus.Warnf("Failed to close database connection: %v", err)
+		}
+	}()
+
+	columns := []string{}
+	for _, feature := range vandalismFeatureColumns {
+		columns = append(columns, fmt.Sprintf("`%s`", feature.column))
+	}
+	rows, err := db.Query("SELECT `id`, `new_id`, `user`, `article`, `reason`, `reverted`, "+strings.Join(columns, ", ")+
+		" FROM `vandalism` WHERE `id` BETWEEN ? AND ? ORDER BY `id`", firstId, lastId)
+	if err != nil {
+		logger.Errorf("Error running query: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.Warnf("Failed to close rows: %v", err)
+		}
+	}()
+
+	decisions := []VandalismDecision{}
+	for rows.Next() {
+		decision := VandalismDecision{Features: map[string]float64{}}
+		values := make([]sql.NullFloat64, len(vandalismFeatureColumns))
+		destinations := []interface{}{&decision.Id, &decision.RevisionId, &decision.User, &decision.Title, &decision.Reason, &decision.Reverted}
+		for i := range values {
+			destinations = append(destinations, &values[i])
+		}
+		if err := rows.Scan(destinations...); err != nil {
+			logger.Errorf("Error reading rows for query: %v", err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+
+		// Rows written before the feature columns existed (or by the legacy bot) leave them NULL
+		for i, value := range values {
+			if value.Valid {
+				decision.Features[vandalismFeatureColumns[i].feature] = value.Float64
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+
+	logger.Debugf("Found %d decisions", len(decisions))
+	return decisions, rows.Err()
+}
+
 func (ci *CluebotInstance) MarkVandalismRevertedSuccessfully(l *logrus.Entry, ctx context.Context, vandalismId int64) error {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.cluebot.MarkVandalismRevertedSuccessfully",
@@ -103,13 +279,14 @@ func (ci *CluebotInstance) MarkVandalismRevertedSuccessfully(l *logrus.Entry, ct
 	return nil
 }
 
-func (ci *CluebotInstance) MarkVandalismRevertBeaten(l *logrus.Entry, ctx context.Context, vandalismId int64, pageTitle, diffUrl, beatenUser string) error {
+func (ci *CluebotInstance) MarkVandalismRevertBeaten(l *logrus.Entry, ctx context.Context, vandalismId int64, pageTitle, diffUrl, beatenUser, state string) error {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.cluebot.MarkVandalismRevertBeaten",
 		"args": map[string]interface{}{
 			"vandalismId": vandalismId,
 			"beatenUser":  beatenUser,
 			"pageTitle":   pageTitle,
+			"state":       state,
 		},
 	})
 	_, span := metrics.OtelTracer.Start(ctx, "cluebot.MarkVandalismRevertBeaten")
@@ -133,7 +310,7 @@ func (ci *CluebotInstance) MarkVandalismRevertBeaten(l *logrus.Entry, ctx contex
 		return err
 	}
 
-	if _, err := db.Exec("INSERT INTO `beaten` (`id`, `article`, `diff`, `user`) VALUES (NULL, ?, ?, ?)", pageTitle, diffUrl, beatenUser); err != nil {
+	if _, err := db.Exec("INSERT INTO `beaten` (`id`, `article`, `diff`, `user`, `state`) VALUES (NULL, ?, ?, ?, ?)", pageTitle, diffUrl, beatenUser, state); err != nil {
 		logger.Errorf("Error running beaten query: %v", err)
 		span.SetStatus(codes.Error, err.Error())
 		return err
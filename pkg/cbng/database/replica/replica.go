@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"net"
 	"strings"
+	"time"
 )
 
 type ReplicaInstance struct {
@@ -21,6 +22,11 @@ func NewReplicaInstance(configuration *config.Configuration) *ReplicaInstance {
 	return &ri
 }
 
+// mediawikiTimestamp formats a unix timestamp the way rev_timestamp is stored
+func mediawikiTimestamp(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format("20060102150405")
+}
+
 func (ri *ReplicaInstance) getDatabaseConnection() (*sql.DB, error) {
 	logger := logrus.WithFields(logrus.Fields{
 		"function": "database.replica.getDatabaseConnection",
@@ -84,13 +90,16 @@ func (ri *ReplicaInstance) GetPageCreatedTimeAndUser(l *logrus.Entry, namespaceI
 	return user, timestamp, nil
 }
 
-func (ri *ReplicaInstance) GetPageRecentEditCount(l *logrus.Entry, namespaceId int64, title string, timestamp int64) (int64, error) {
+// GetPageRecentEditCount counts every edit to the page up to before. Despite the name there is no lower bound: core was trained on
+// lifetime counts (the original window compared rev_timestamp with a unix time, which always matched), so adding
+// a real window changes the features core sees and needs core retraining first
+func (ri *ReplicaInstance) GetPageRecentEditCount(l *logrus.Entry, namespaceId int64, title string, before int64) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetPageRecentEditCount",
 		"args": map[string]interface{}{
 			"namespaceId": namespaceId,
 			"title":       title,
-			"timestamp":   timestamp,
+			"before":      before,
 		},
 	})
 
@@ -109,7 +118,7 @@ func (ri *ReplicaInstance) GetPageRecentEditCount(l *logrus.Entry, namespaceId i
 	rows, err := db.Query("SET STATEMENT max_statement_time=10 FOR "+
 		"SELECT COUNT(*) as count FROM `page` "+
 		"JOIN `revision` ON `rev_page` = `page_id` "+
-		"WHERE `page_namespace` = ? AND `page_title` = ? AND `rev_timestamp` > ?", namespaceId, title, timestamp)
+		"WHERE `page_namespace` = ? AND `page_title` = ? AND `rev_timestamp` <= ?", namespaceId, title, mediawikiTimestamp(before))
 
 	if err != nil {
 		return recentEditCount, err
@@ -133,13 +142,14 @@ func (ri *ReplicaInstance) GetPageRecentEditCount(l *logrus.Entry, namespaceId i
 	return recentEditCount, nil
 }
 
-func (ri *ReplicaInstance) GetPageRecentRevertCount(l *logrus.Entry, namespaceId int64, title string, timestamp int64) (int64, error) {
+// GetPageRecentRevertCount counts every revert of the page up to before, with no lower bound for the same reason as GetPageRecentEditCount
+func (ri *ReplicaInstance) GetPageRecentRevertCount(l *logrus.Entry, namespaceId int64, title string, before int64) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetPageRecentRevertCount",
 		"args": map[string]interface{}{
 			"namespaceId": namespaceId,
 			"title":       title,
-			"timestamp":   timestamp,
+			"before":      before,
 		},
 	})
 
@@ -159,8 +169,8 @@ func (ri *ReplicaInstance) GetPageRecentRevertCount(l *logrus.Entry, namespaceId
 		"SELECT COUNT(*) as count FROM `page` "+
 		"JOIN `revision` ON `rev_page` = `page_id` "+
 		"JOIN `comment` ON `comment_id` = `rev_comment_id` "+
-		"WHERE `page_namespace` = ? AND `page_title` = ? AND `rev_timestamp` > ? AND `comment_text` "+
-		"LIKE 'Revert%'", namespaceId, title, timestamp)
+		"WHERE `page_namespace` = ? AND `page_title` = ? AND `rev_timestamp` <= ? AND `comment_text` "+
+		"LIKE 'Revert%'", namespaceId, title, mediawikiTimestamp(before))
 
 	if err != nil {
 		return recentRevertCount, err
@@ -185,11 +195,12 @@ func (ri *ReplicaInstance) GetPageRecentRevertCount(l *logrus.Entry, namespaceId
 	return recentRevertCount, nil
 }
 
-func (ri *ReplicaInstance) GetAnonymousUserEditCount(l *logrus.Entry, user string) (int64, error) {
+func (ri *ReplicaInstance) GetAnonymousUserEditCount(l *logrus.Entry, user string, before int64) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetUserEditCount",
 		"args": map[string]interface{}{
-			"user": user,
+			"user":   user,
+			"before": before,
 		},
 	})
 
@@ -204,6 +215,7 @@ func (ri *ReplicaInstance) GetAnonymousUserEditCount(l *logrus.Entry, user strin
 		}
 	}()
 
+	// IPs are always reported with 0 edits, as core was trained, so there is nothing for before to bound
 	var editCount int64
 	logger.Debugf("Querying user_editcount for user")
 	userCountRows, err := db.Query("SET STATEMENT max_statement_time=10 FOR "+
@@ -229,11 +241,12 @@ func (ri *ReplicaInstance) GetAnonymousUserEditCount(l *logrus.Entry, user strin
 	return 0, nil
 }
 
-func (ri *ReplicaInstance) GetRegisteredUserEditCount(l *logrus.Entry, user string) (int64, error) {
+func (ri *ReplicaInstance) GetRegisteredUserEditCount(l *logrus.Entry, user string, before int64) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetUserEditCount",
 		"args": map[string]interface{}{
-			"user": user,
+			"user":   user,
+			"before": before,
 		},
 	})
 
@@ -253,7 +266,7 @@ func (ri *ReplicaInstance) GetRegisteredUserEditCount(l *logrus.Entry, user stri
 	rows, err := db.Query("SET STATEMENT max_statement_time=10 FOR "+
 		"SELECT COUNT(*) AS `user_editcount` FROM `revision_userindex` "+
 		"WHERE `rev_actor` = "+
-		"(SELECT actor_id FROM actor WHERE `actor_name` = ?)", user)
+		"(SELECT actor_id FROM actor WHERE `actor_name` = ?) AND `rev_timestamp` <= ?", user, mediawikiTimestamp(before))
 	if err != nil {
 		return editCount, err
 	}
@@ -274,6 +287,8 @@ func (ri *ReplicaInstance) GetRegisteredUserEditCount(l *logrus.Entry, user stri
 	return editCount, nil
 }
 
+// GetUserRegistrationTime needs no bound for replayed revisions, an account is registered (and first edits) before any
+// revision it makes
 func (ri *ReplicaInstance) GetUserRegistrationTime(l *logrus.Entry, user string) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetUserEditCount",
@@ -340,11 +355,12 @@ func (ri *ReplicaInstance) GetUserRegistrationTime(l *logrus.Entry, user string)
 	return registrationTime, nil
 }
 
-func (ri *ReplicaInstance) GetUserWarnCount(l *logrus.Entry, user string) (int64, error) {
+func (ri *ReplicaInstance) GetUserWarnCount(l *logrus.Entry, user string, before int64) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetUserWarnCount",
 		"args": map[string]interface{}{
-			"user": user,
+			"user":   user,
+			"before": before,
 		},
 	})
 
@@ -365,8 +381,9 @@ func (ri *ReplicaInstance) GetUserWarnCount(l *logrus.Entry, user string) (int64
 		"JOIN `revision` ON `rev_page` = `page_id` "+
 		"JOIN `comment` ON `comment_id` = `rev_comment_id` "+
 		"WHERE `page_namespace` = 3 AND `page_title` = ? AND "+
+		"`rev_timestamp` <= ? AND "+
 		"(`comment_text` LIKE '%warning%' OR "+
-		"`comment_text` LIKE 'General note: Nonconstructive%')", strings.ReplaceAll(user, " ", "_"))
+		"`comment_text` LIKE 'General note: Nonconstructive%')", strings.ReplaceAll(user, " ", "_"), mediawikiTimestamp(before))
 	if err != nil {
 		return warningCount, err
 	}
@@ -389,11 +406,12 @@ func (ri *ReplicaInstance) GetUserWarnCount(l *logrus.Entry, user string) (int64
 	return warningCount, nil
 }
 
-func (ri *ReplicaInstance) GetUserDistinctPagesCount(l *logrus.Entry, user string) (int64, error) {
+func (ri *ReplicaInstance) GetUserDistinctPagesCount(l *logrus.Entry, user string, before int64) (int64, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "database.replica.GetUserDistinctPagesCount",
 		"args": map[string]interface{}{
-			"user": user,
+			"user":   user,
+			"before": before,
 		},
 	})
 
@@ -411,7 +429,7 @@ func (ri *ReplicaInstance) GetUserDistinctPagesCount(l *logrus.Entry, user strin
 	var distinctPageCount int64
 	rows, err := db.Query("SET STATEMENT max_statement_time=10 FOR "+
 		"SELECT COUNT(DISTINCT rev_page) AS count FROM `revision_userindex` WHERE `rev_actor` = "+
-		"(SELECT actor_id FROM actor WHERE `actor_name` = ?)", strings.ReplaceAll(user, " ", "_"))
+		"(SELECT actor_id FROM actor WHERE `actor_name` = ?) AND `rev_timestamp` <= ?", strings.ReplaceAll(user, " ", "_"), mediawikiTimestamp(before))
 	if err != nil {
 		return distinctPageCount, err
 	}
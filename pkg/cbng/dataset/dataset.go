@@ -0,0 +1,141 @@
+package dataset
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/database"
+	"github.com/cluebotng/botng/pkg/cbng/feed"
+	"github.com/cluebotng/botng/pkg/cbng/loader"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type LabelledRevision struct {
+	RevisionId  int64
+	IsVandalism bool
+}
+
+type ExportResult struct {
+	Exported int
+	Failed   map[int64]error
+}
+
+func parseLabel(label string) (bool, error) {
+	switch strings.ToLower(label) {
+	case "v", "vandalism", "true", "1":
+		return true, nil
+	case "c", "constructive", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown label '%s'", label)
+}
+
+// ReadLabelledRevisions parses lines of "<revision id> <vandalism|constructive>", ignoring blanks and # comments
+func ReadLabelledRevisions(r io.Reader) ([]LabelledRevision, error) {
+	revisions := []LabelledRevision{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(c rune) bool { return c == ',' || c == ' ' || c == '\t' })
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected '<revision id> <label>', got '%s'", lineNumber, line)
+		}
+
+		revisionId, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid revision id '%s': %v", lineNumber, fields[0], err)
+		}
+
+		isVandalism, err := parseLabel(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		revisions = append(revisions, LabelledRevision{RevisionId: revisionId, IsVandalism: isVandalism})
+	}
+	return revisions, scanner.Err()
+}
+
+func Export(l *logrus.Entry, db *database.DatabaseConnection, api *wikipedia.WikipediaApi, revisions []LabelledRevision, w io.Writer) (*ExportResult, error) {
+	logger := l.WithField("function", "dataset.Export")
+	ctx, span := metrics.OtelTracer.Start(context.Background(), "dataset.Export")
+	defer span.End()
+
+	result := ExportResult{Failed: map[int64]error{}}
+
+	if _, err := io.WriteString(w, "<WPEditSet>\n"); err != nil {
+		return nil, err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	for _, revision := range revisions {
+		change, err := feed.NewChangeFromRevisionId(logger, api, revision.RevisionId)
+		if err == nil {
+			err = loader.LoadChange(change.Logger, ctx, db, api, change)
+		}
+		if err != nil {
+			logger.Warnf("Skipping %d: %v", revision.RevisionId, err)
+			result.Failed[revision.RevisionId] = err
+			continue
+		}
+
+		edit := model.NewWPEdit(change)
+		edit.IsVandalism = &revision.IsVandalism
+		if err := encoder.Encode(edit); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return nil, err
+		}
+		result.Exported++
+	}
+
+	if _, err := io.WriteString(w, "</WPEditSet>\n"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func ExportFile(l *logrus.Entry, db *database.DatabaseConnection, api *wikipedia.WikipediaApi, inputPath, outputPath string) (*ExportResult, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := input.Close(); err != nil {
+			l.Warnf("Failed to close input file: %v", err)
+		}
+	}()
+
+	revisions, err := ReadLabelledRevisions(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", inputPath, err)
+	}
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := output.Close(); err != nil {
+			l.Warnf("Failed to close output file: %v", err)
+		}
+	}()
+
+	return Export(l, db, api, revisions, output)
+}
@@ -0,0 +1,112 @@
+package exclusion
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MessageVandalism is the {{bots|optout=}} message type used for vandalism warnings
+const MessageVandalism = "vandalism"
+
+// Template is a parsed {{bots}} or {{nobots}} transclusion
+type Template struct {
+	Raw        string
+	NoBots     bool
+	Positional []string
+	Params     map[string][]string
+}
+
+var templateRegex = regexp.MustCompile(`(?is)\{\{\s*(?:template\s*:\s*)?(no)?bots\s*(\|[^{}]*)?\}\}`)
+
+func normaliseName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " "))
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = normaliseName(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Parse returns every {{bots}}/{{nobots}} template found in the wikitext
+func Parse(text string) []Template {
+	templates := []Template{}
+	for _, match := range templateRegex.FindAllStringSubmatch(text, -1) {
+		template := Template{
+			Raw:    match[0],
+			NoBots: match[1] != "",
+			Params: map[string][]string{},
+		}
+		for _, part := range strings.Split(strings.TrimPrefix(match[2], "|"), "|") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			if key, value, ok := strings.Cut(part, "="); ok {
+				key = strings.ToLower(strings.TrimSpace(key))
+				template.Params[key] = append(template.Params[key], splitList(value)...)
+			} else {
+				template.Positional = append(template.Positional, splitList(part)...)
+			}
+		}
+		templates = append(templates, template)
+	}
+	return templates
+}
+
+func listMatches(list []string, names []string, wildcard string) bool {
+	for _, item := range list {
+		// The legacy bot treated * the same as all
+		if item == wildcard || item == "*" {
+			return true
+		}
+		for _, name := range names {
+			if item == normaliseName(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Denies reports if the template excludes any of the bot names, optionally for a message type
+func (t Template) Denies(names []string, messageType string) bool {
+	if optout, ok := t.Params["optout"]; ok && messageType != "" {
+		if listMatches(optout, []string{messageType}, "all") {
+			return true
+		}
+	}
+
+	if t.NoBots {
+		// {{nobots}} denies everyone, {{nobots|a,b}} or {{nobots|deny=a,b}} only those listed
+		if allow, ok := t.Params["allow"]; ok {
+			return !listMatches(allow, names, "all")
+		}
+		deny := append(append([]string{}, t.Positional...), t.Params["deny"]...)
+		if len(deny) == 0 {
+			return true
+		}
+		return listMatches(deny, names, "all")
+	}
+
+	if allow, ok := t.Params["allow"]; ok && !listMatches(allow, names, "all") {
+		return true
+	}
+	if deny, ok := t.Params["deny"]; ok && listMatches(deny, names, "all") {
+		return true
+	}
+	return false
+}
+
+// Check returns the first template in the wikitext that excludes the bot, or nil if editing is allowed
+func Check(text string, names []string, messageType string) *Template {
+	for _, template := range Parse(text) {
+		if template.Denies(names, messageType) {
+			return &template
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package exclusion
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	botNames := []string{"ClueBot NG"}
+
+	tests := []struct {
+		name        string
+		text        string
+		messageType string
+		excluded    bool
+	}{
+		{"no template", "Some article text", "", false},
+		{"nobots", "{{nobots}}", "", true},
+		{"nobots capitalised", "{{Nobots}}", "", true},
+		{"nobots with namespace", "{{Template:nobots}}", "", true},
+		{"bots", "{{bots}}", "", false},
+		{"allow none", "{{bots|allow=none}}", "", true},
+		{"allow all", "{{bots|allow=all}}", "", false},
+		{"deny all", "{{bots|deny=all}}", "", true},
+		{"deny none", "{{bots|deny=none}}", "", false},
+		{"allow list including bot", "{{bots|allow=SineBot,ClueBot NG}}", "", false},
+		{"allow list excluding bot", "{{bots|allow=SineBot,AnomieBOT}}", "", true},
+		{"deny list including bot", "{{bots|deny=SineBot,ClueBot NG}}", "", true},
+		{"deny list excluding bot", "{{bots|deny=SineBot,AnomieBOT}}", "", false},
+		{"nobots deny list including bot", "{{nobots|deny=ClueBot NG}}", "", true},
+		{"nobots deny list excluding bot", "{{nobots|deny=SineBot}}", "", false},
+		{"bot name lower case", "{{bots|deny=cluebot ng}}", "", true},
+		{"bot name upper case", "{{bots|deny=CLUEBOT NG}}", "", true},
+		{"bot name with underscore", "{{bots|deny=ClueBot_NG}}", "", true},
+		{"bot name with extra whitespace", "{{bots|deny= ClueBot   NG }}", "", true},
+		{"template with whitespace", "{{ bots | deny = ClueBot NG }}", "", true},
+		{"template within text", "Article text\n{{bots|deny=ClueBot NG}}\nMore text", "", true},
+		{"optout matching message", "{{bots|optout=vandalism}}", MessageVandalism, true},
+		{"optout all", "{{bots|optout=all}}", MessageVandalism, true},
+		{"optout other message", "{{bots|optout=nosource}}", MessageVandalism, false},
+		{"optout without message type", "{{bots|optout=vandalism}}", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := Check(tt.text, botNames, tt.messageType)
+			if tt.excluded && template == nil {
+				t.Errorf("expected %q to exclude the bot", tt.text)
+			}
+			if !tt.excluded && template != nil {
+				t.Errorf("expected %q to allow the bot, excluded by %s", tt.text, template.Raw)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	templates := Parse("{{bots|allow=ClueBot NG, SineBot}}{{nobots|deny=AnomieBOT}}")
+	if len(templates) != 2 {
+		t.Fatalf("expected 2 templates, got %+v", templates)
+	}
+
+	if templates[0].NoBots {
+		t.Errorf("expected the first template to be {{bots}}")
+	}
+	allow := templates[0].Params["allow"]
+	if len(allow) != 2 || allow[0] != "cluebot ng" || allow[1] != "sinebot" {
+		t.Errorf("unexpected allow list %v", allow)
+	}
+
+	if !templates[1].NoBots {
+		t.Errorf("expected the second template to be {{nobots}}")
+	}
+	if deny := templates[1].Params["deny"]; len(deny) != 1 || deny[0] != "anomiebot" {
+		t.Errorf("unexpected deny list %v", deny)
+	}
+}
@@ -0,0 +1,198 @@
+package feed
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Ranges are expanded in memory, so refuse anything that could not reasonably be processed in one run
+const maxRevisionRange = 100000
+
+type BatchSummary struct {
+	Submitted int
+	Emitted   int
+	Failed    map[int64]error
+	Outcomes  map[int64]string
+}
+
+// Count returns the number of submitted revisions that finished with the outcome
+func (s *BatchSummary) Count(outcome string) int {
+	count := 0
+	for _, revisionOutcome := range s.Outcomes {
+		if revisionOutcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// UniqueRevisionIds drops repeated IDs, keeping the first occurrence, so each revision is processed and counted once
+func UniqueRevisionIds(revisionIds []int64) []int64 {
+	seen := map[int64]bool{}
+	unique := make([]int64, 0, len(revisionIds))
+	for _, revisionId := range revisionIds {
+		if !seen[revisionId] {
+			seen[revisionId] = true
+			unique = append(unique, revisionId)
+		}
+	}
+	return unique
+}
+
+// ReadRevisionIdsFile reads one revision ID per line, ignoring blank lines, comments and repeated IDs
+func ReadRevisionIdsFile(path string) ([]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			logrus.Warnf("Failed to close revision id file: %v", err)
+		}
+	}()
+
+	revisionIds := []int64{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		revisionId, err := strconv.ParseInt(strings.Fields(line)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid revision id '%s': %v", lineNumber, line, err)
+		}
+		revisionIds = append(revisionIds, revisionId)
+	}
+	return UniqueRevisionIds(revisionIds), scanner.Err()
+}
+
+// ParseIdRange parses an inclusive "<start>-<end>" range
+func ParseIdRange(idRange string) (int64, int64, error) {
+	parts := strings.SplitN(idRange, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected '<start>-<end>', got '%s'", idRange)
+	}
+
+	start, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start '%s': %v", parts[0], err)
+	}
+	end, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range end '%s': %v", parts[1], err)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range end %d is before start %d", end, start)
+	}
+	return start, end, nil
+}
+
+func ParseRevisionRange(revisionRange string) ([]int64, error) {
+	start, end, err := ParseIdRange(revisionRange)
+	if err != nil {
+		return nil, err
+	}
+	if end-start+1 > maxRevisionRange {
+		return nil, fmt.Errorf("range %d-%d covers %d revisions, the maximum is %d", start, end, end-start+1, maxRevisionRange)
+	}
+
+	revisionIds := make([]int64, 0, end-start+1)
+	for revisionId := start; revisionId <= end; revisionId++ {
+		revisionIds = append(revisionIds, revisionId)
+	}
+	return revisionIds, nil
+}
+
+func GetUserRevisionIds(api *wikipedia.WikipediaApi, user string, limit int) ([]int64, error) {
+	logger := logrus.WithFields(logrus.Fields{"function": "feed.GetUserRevisionIds"})
+	return api.GetUserContributions(logger, context.Background(), user, limit)
+}
+
+// EmitBatchEdits submits each revision to the pipeline and returns once every emitted change has completed
+func EmitBatchEdits(api *wikipedia.WikipediaApi, revisionIds []int64, concurrency int, changeFeed chan<- *model.ProcessEvent) *BatchSummary {
+	logger := logrus.WithFields(logrus.Fields{"function": "feed.EmitBatchEdits"})
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	summary := BatchSummary{Submitted: len(revisionIds), Failed: map[int64]error{}, Outcomes: map[int64]string{}}
+	mutex := sync.Mutex{}
+	pending := make(chan int64)
+
+	var completed sync.WaitGroup
+	record := func(revisionId int64, outcome string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		summary.Outcomes[revisionId] = outcome
+		logger.WithFields(logrus.Fields{"revisionId": revisionId, "outcome": outcome}).Infof("Revision %d %s", revisionId, outcome)
+	}
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for revisionId := range pending {
+				revisionLogger := logger.WithField("revisionId", revisionId)
+				change, err := NewChangeFromRevisionId(revisionLogger, api, revisionId)
+				if err != nil {
+					revisionLogger.Warnf("Failed to emit %d: %v", revisionId, err)
+					mutex.Lock()
+					summary.Failed[revisionId] = err
+					mutex.Unlock()
+					record(revisionId, model.OutcomeFailed)
+					continue
+				}
+
+				once := sync.Once{}
+				completed.Add(1)
+				change.OnComplete = func(outcome string) {
+					once.Do(func() {
+						record(change.Current.Id, outcome)
+						completed.Done()
+					})
+				}
+
+				mutex.Lock()
+				summary.Emitted++
+				mutex.Unlock()
+				emitChange(revisionLogger, change, changeFeed)
+			}
+		}()
+	}
+
+	for _, revisionId := range revisionIds {
+		pending <- revisionId
+	}
+	close(pending)
+	workers.Wait()
+	completed.Wait()
+
+	failures := map[string]string{}
+	for revisionId, err := range summary.Failed {
+		failures[strconv.FormatInt(revisionId, 10)] = err.Error()
+	}
+	logger.WithFields(logrus.Fields{
+		"submitted": summary.Submitted,
+		"emitted":   summary.Emitted,
+		"scored":    summary.Count(model.OutcomeScored),
+		"skipped":   summary.Count(model.OutcomeSkipped),
+		"failed":    summary.Count(model.OutcomeFailed),
+		"failures":  failures,
+	}).Infof("Batch complete: %d submitted, %d scored, %d skipped, %d failed",
+		summary.Submitted, summary.Count(model.OutcomeScored), summary.Count(model.OutcomeSkipped), summary.Count(model.OutcomeFailed))
+	return &summary
+}
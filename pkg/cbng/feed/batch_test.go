@@ -0,0 +1,96 @@
+package feed
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseIdRange(t *testing.T) {
+	tests := []struct {
+		idRange string
+		start   int64
+		end     int64
+		invalid bool
+	}{
+		{"1-10", 1, 10, false},
+		{" 5 - 5 ", 5, 5, false},
+		{"10-1", 0, 0, true},
+		{"10", 0, 0, true},
+		{"a-10", 0, 0, true},
+		{"1-b", 0, 0, true},
+		{"-1-10", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.idRange, func(t *testing.T) {
+			start, end, err := ParseIdRange(tt.idRange)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("expected an error, got %d-%d", start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if start != tt.start || end != tt.end {
+				t.Errorf("expected %d-%d, got %d-%d", tt.start, tt.end, start, end)
+			}
+		})
+	}
+}
+
+func TestParseRevisionRange(t *testing.T) {
+	revisionIds, err := ParseRevisionRange("100-103")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(revisionIds, []int64{100, 101, 102, 103}) {
+		t.Errorf("unexpected revisions %v", revisionIds)
+	}
+
+	if _, err := ParseRevisionRange("1-100000"); err != nil {
+		t.Errorf("expected the maximum range to be accepted: %v", err)
+	}
+	if _, err := ParseRevisionRange("1-100001"); err == nil {
+		t.Errorf("expected a range over the maximum to be refused")
+	}
+}
+
+func TestReadRevisionIdsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revisions")
+	data := "# revisions to replay\n100\n\n  200 vandalism\n100\n300\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write revisions: %v", err)
+	}
+
+	revisionIds, err := ReadRevisionIdsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(revisionIds, []int64{100, 200, 300}) {
+		t.Errorf("expected unique revisions in file order, got %v", revisionIds)
+	}
+}
+
+func TestReadRevisionIdsFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revisions")
+	if err := os.WriteFile(path, []byte("100\nabc\n"), 0o600); err != nil {
+		t.Fatalf("failed to write revisions: %v", err)
+	}
+
+	if _, err := ReadRevisionIdsFile(path); err == nil {
+		t.Errorf("expected an invalid line to be refused")
+	}
+	if _, err := ReadRevisionIdsFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected a missing file to be refused")
+	}
+}
+
+func TestUniqueRevisionIds(t *testing.T) {
+	if unique := UniqueRevisionIds([]int64{3, 1, 3, 2, 1}); !reflect.DeepEqual(unique, []int64{3, 1, 2}) {
+		t.Errorf("unexpected revisions %v", unique)
+	}
+}
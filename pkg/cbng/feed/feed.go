@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/cluebotng/botng/pkg/cbng/config"
 	"github.com/cluebotng/botng/pkg/cbng/helpers"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
@@ -188,28 +189,28 @@ func ConsumeHttpChangeEvents(wg *sync.WaitGroup, configuration *config.Configura
 	}
 }
 
-func EmitSingleEdit(api *wikipedia.WikipediaApi, changeId int64, changeFeed chan<- *model.ProcessEvent) {
-	logger := logrus.WithFields(logrus.Fields{"function": "feed.EmitSingleEdit"})
-
-	revisionMeta := api.GetRevisionMetadata(logger, changeId)
-	if revisionMeta == nil {
-		logger.Fatalf("Could not get revision metadata")
-		return
+func NewChangeFromRevisionId(logger *logrus.Entry, api *wikipedia.WikipediaApi, changeId int64) (*model.ProcessEvent, error) {
+	revisionMeta, err := api.GetRevisionMetadata(logger, changeId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revision metadata for %d: %w", changeId, err)
 	}
 
-	revisionHistory := *api.GetRevisionHistory(logger, context.Background(), revisionMeta.Title, changeId)
+	revisionHistory, err := api.GetRevisionHistory(logger, context.Background(), revisionMeta.Title, changeId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revision history for %d: %w", changeId, err)
+	}
 	if len(revisionHistory) < 2 {
-		logger.Fatalf("Could not get revision history")
-		return
+		return nil, fmt.Errorf("could not get revision history for %d: %w", changeId, wikipedia.ErrIncompleteData)
 	}
 
 	changeUUID := uuid.NewV4().String()
-	receivedTime := time.Now().UTC()
 	changeTime := time.Unix(revisionMeta.Timestamp, 0)
+	// The replica lookups are bounded by the received time, so an older revision sees the history as of when it was made
+	receivedTime := changeTime
 
 	_, span := metrics.OtelTracer.Start(context.Background(), "handleEdit")
 	span.SetAttributes(attribute.String("uuid", changeUUID))
-	span.SetAttributes(attribute.Int64("time_until_received", receivedTime.Unix()-changeTime.Unix()))
+	span.SetAttributes(attribute.Int64("time_until_received", time.Now().UTC().Unix()-changeTime.Unix()))
 	defer span.End()
 
 	change := model.ProcessEvent{
@@ -244,8 +245,19 @@ func EmitSingleEdit(api *wikipedia.WikipediaApi, changeId int64, changeFeed chan
 			Id: revisionHistory[1].Id,
 		},
 	}
+	return &change, nil
+}
+
+func EmitSingleEdit(logger *logrus.Entry, api *wikipedia.WikipediaApi, changeId int64, changeFeed chan<- *model.ProcessEvent) error {
+	change, err := NewChangeFromRevisionId(logger, api, changeId)
+	if err != nil {
+		return err
+	}
+	emitChange(logger, change, changeFeed)
+	return nil
+}
 
-	// Otherwise send for processing
+func emitChange(logger *logrus.Entry, change *model.ProcessEvent, changeFeed chan<- *model.ProcessEvent) {
 	logger.WithFields(logrus.Fields{
 		"uuid": change.Uuid,
 		"change": map[string]interface{}{
@@ -256,7 +268,8 @@ func EmitSingleEdit(api *wikipedia.WikipediaApi, changeId int64, changeFeed chan
 			"curid":     change.Current.Id,
 		},
 	}).Info("Received new event")
+	metrics.EditStatus.With(prometheus.Labels{"state": "received_new", "status": "success"}).Inc()
 
 	change.StartNewActiveSpan("pending.Replication")
-	changeFeed <- &change
+	changeFeed <- change
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"net"
+	"strconv"
 	"strings"
 )
 
@@ -72,6 +73,19 @@ func NameSpaceIdToName(nsid int64) string {
 	return namespacesById[nsid]
 }
 
+// ParseNamespaceIds parses a comma separated list of namespace ids, as used by the `namespace` conditions
+func ParseNamespaceIds(ids string) (map[int64]bool, error) {
+	namespaceIds := map[int64]bool{}
+	for _, value := range strings.Split(ids, ",") {
+		namespaceId, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace id '%s': %v", value, err)
+		}
+		namespaceIds[namespaceId] = true
+	}
+	return namespaceIds, nil
+}
+
 func FormatPlusOrMinus(value int64) string {
 	if value < 0 {
 		return fmt.Sprintf("%d", value)
@@ -0,0 +1,42 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/database"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// LoadChange runs every loader against a single change, in the same order as the live pipeline
+func LoadChange(l *logrus.Entry, parentCtx context.Context, db *database.DatabaseConnection, api *wikipedia.WikipediaApi, change *model.ProcessEvent) error {
+	logger := l.WithField("function", "loader.LoadChange")
+	ctx, span := metrics.OtelTracer.Start(parentCtx, "LoadChange")
+	defer span.End()
+
+	steps := []struct {
+		name string
+		load func() error
+	}{
+		{"page metadata", func() error { return loadPageMetadata(logger, db, change) }},
+		{"page recent edit count", func() error { return loadPageRecentEditCount(logger, db, change) }},
+		{"page recent revert count", func() error { return loadPageRecentRevertCount(logger, db, change) }},
+		{"user edit count", func() error { return loadUserEditCount(logger, db, change) }},
+		{"user registration time", func() error { return loadUserRegistrationTime(logger, db, change) }},
+		{"user distinct pages count", func() error { return loadDistinctPagesCount(logger, db, change) }},
+		{"user warns count", func() error { return loadUserWarnsCount(logger, db, change) }},
+		{"page revision", func() error { return loadPageRevision(logger, ctx, api, nil, change) }},
+		{"page diff", func() error { loadPageDiff(logger, ctx, api, change); return nil }},
+	}
+
+	for _, step := range steps {
+		if err := step.load(); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("failed to load %s: %v", step.name, err)
+		}
+	}
+	return nil
+}
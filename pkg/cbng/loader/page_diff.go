@@ -0,0 +1,27 @@
+package loader
+
+import (
+	"context"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// loadPageDiff is best effort and only used for logging & explain, core scores the full page texts
+func loadPageDiff(logger *logrus.Entry, ctx context.Context, api *wikipedia.WikipediaApi, change *model.ProcessEvent) {
+	if change.Previous.Id == 0 {
+		return
+	}
+
+	diff, err := api.Compare(logger, ctx, change.Previous.Id, change.Current.Id)
+	if err != nil {
+		logger.Warnf("Failed to load diff: %v", err)
+		metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_diff", "status": "failed"}).Inc()
+		return
+	}
+	change.Diff = model.NewProcessEventDiff(diff.AddedLines, diff.RemovedLines, diff.Added, diff.Removed)
+	logger.Infof("Loaded diff: %+v", *change.Diff)
+	metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_diff", "status": "success"}).Inc()
+}
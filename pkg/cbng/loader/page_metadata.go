@@ -8,10 +8,21 @@ import (
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
 )
 
+func loadPageMetadata(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	pageCreatedUser, pageCreatedTimestamp, err := db.Replica.GetPageCreatedTimeAndUser(logger, change.Common.NamespaceId, helpers.PageTitleWithoutNamespace(change.Common.Title))
+	if err != nil {
+		return err
+	}
+	change.Common.Creator = pageCreatedUser
+	change.Common.PageMadeTime = pageCreatedTimestamp
+	return nil
+}
+
 func LoadPageMetadata(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -26,16 +37,14 @@ func LoadPageMetadata(wg *sync.WaitGroup, db *database.DatabaseConnection, r *re
 
 			logger := change.Logger.WithField("function", "loader.LoadPageMetadata")
 
-			pageCreatedUser, pageCreatedTimestamp, err := db.Replica.GetPageCreatedTimeAndUser(logger, change.Common.NamespaceId, helpers.PageTitleWithoutNamespace(change.Common.Title))
-			if err != nil {
+			if err := loadPageMetadata(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_metadata", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get page metadata", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_metadata", "status": "success"}).Inc()
-				change.Common.Creator = pageCreatedUser
-				change.Common.PageMadeTime = pageCreatedTimestamp
 				change.StartNewActiveSpan("pending.LoadPageRecentEditCount")
 				outChangeFeed <- change
 			}
@@ -2,17 +2,26 @@ package loader
 
 import (
 	"fmt"
-	"github.com/cluebotng/botng/pkg/cbng/config"
 	"github.com/cluebotng/botng/pkg/cbng/database"
 	"github.com/cluebotng/botng/pkg/cbng/helpers"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
 )
 
+func loadPageRecentEditCount(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	pageRecentEditCount, err := db.Replica.GetPageRecentEditCount(logger, change.Common.NamespaceId, helpers.PageTitleWithoutNamespace(change.Common.Title), change.ReceivedTime.Unix())
+	if err != nil {
+		return err
+	}
+	change.Common.NumRecentEdits = pageRecentEditCount
+	return nil
+}
+
 func LoadPageRecentEditCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -27,15 +36,14 @@ func LoadPageRecentEditCount(wg *sync.WaitGroup, db *database.DatabaseConnection
 
 			logger := change.Logger.WithField("function", "loader.LoadPageRecentEditCount")
 
-			pageRecentEditCount, err := db.Replica.GetPageRecentEditCount(logger, change.Common.NamespaceId, helpers.PageTitleWithoutNamespace(change.Common.Title), change.ReceivedTime.Unix()-config.RecentChangeWindow)
-			if err != nil {
+			if err := loadPageRecentEditCount(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_recent_edits", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get page recent edit count", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_recent_edits", "status": "success"}).Inc()
-				change.Common.NumRecentEdits = pageRecentEditCount
 				change.StartNewActiveSpan("pending.LoadPageRecentRevertCount")
 				outChangeFeed <- change
 			}
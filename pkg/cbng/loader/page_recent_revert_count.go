@@ -2,17 +2,26 @@ package loader
 
 import (
 	"fmt"
-	"github.com/cluebotng/botng/pkg/cbng/config"
 	"github.com/cluebotng/botng/pkg/cbng/database"
 	"github.com/cluebotng/botng/pkg/cbng/helpers"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
 )
 
+func loadPageRecentRevertCount(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	pageRecentRevertCount, err := db.Replica.GetPageRecentRevertCount(logger, change.Common.NamespaceId, helpers.PageTitleWithoutNamespace(change.Common.Title), change.ReceivedTime.Unix())
+	if err != nil {
+		return err
+	}
+	change.Common.NumRecentRevisions = pageRecentRevertCount
+	return nil
+}
+
 func LoadPageRecentRevertCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -26,15 +35,14 @@ func LoadPageRecentRevertCount(wg *sync.WaitGroup, db *database.DatabaseConnecti
 			_, span := metrics.OtelTracer.Start(change.TraceContext, "LoadPageRecentRevertCount")
 			defer span.End()
 
-			pageRecentRevertCount, err := db.Replica.GetPageRecentRevertCount(logger, change.Common.NamespaceId, helpers.PageTitleWithoutNamespace(change.Common.Title), change.ReceivedTime.Unix()-config.RecentChangeWindow)
-			if err != nil {
+			if err := loadPageRecentRevertCount(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_recent_reverts", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get page recent revert count", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_recent_reverts", "status": "success"}).Inc()
-				change.Common.NumRecentRevisions = pageRecentRevertCount
 				change.StartNewActiveSpan("pending.LoadUserEditCount")
 				outChangeFeed <- change
 			}
@@ -1,17 +1,102 @@
 package loader
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
+	"time"
 )
 
-func LoadPageRevision(wg *sync.WaitGroup, api *wikipedia.WikipediaApi, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
+var errUnsupportedContentModel = errors.New("unsupported content model")
+
+func revisionLookupStatus(err error) string {
+	switch {
+	case errors.Is(err, wikipedia.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, wikipedia.ErrSuppressed):
+		return "suppressed"
+	case errors.Is(err, wikipedia.ErrTextHidden):
+		return "text_hidden"
+	case errors.Is(err, wikipedia.ErrUserHidden):
+		return "user_hidden"
+	case errors.Is(err, errUnsupportedContentModel):
+		return "content_model"
+	case errors.Is(err, wikipedia.ErrIncompleteData):
+		return "incomplete"
+	}
+	return "failed"
+}
+
+// getRevisionData prefers a batched lookup by id, falling back to a page query for anything the batch could not load
+func getRevisionData(logger *logrus.Entry, ctx context.Context, api *wikipedia.WikipediaApi, batcher *wikipedia.RevisionBatcher, change *model.ProcessEvent) (*wikipedia.RevisionData, error) {
+	if batcher != nil && change.Previous.Id != 0 {
+		revisions, err := batcher.GetRevisions(logger, ctx, change.Current.Id, change.Previous.Id)
+		if err != nil {
+			logger.Warnf("Batched revision lookup failed, falling back: %v", err)
+		} else {
+			current, hasCurrent := revisions[change.Current.Id]
+			previous, hasPrevious := revisions[change.Previous.Id]
+			if hasCurrent && hasPrevious {
+				metrics.EditStatus.With(prometheus.Labels{"state": "revision_batch", "status": "hit"}).Inc()
+				return &wikipedia.RevisionData{Current: current, Previous: previous}, nil
+			}
+		}
+		metrics.EditStatus.With(prometheus.Labels{"state": "revision_batch", "status": "miss"}).Inc()
+	}
+	return api.GetRevision(logger, ctx, change.Common.Title, change.Current.Id)
+}
+
+func loadPageRevision(logger *logrus.Entry, ctx context.Context, api *wikipedia.WikipediaApi, batcher *wikipedia.RevisionBatcher, change *model.ProcessEvent) error {
+	revisionData, err := getRevisionData(logger, ctx, api, batcher, change)
+	if err != nil {
+		return fmt.Errorf("failed to get revision data: %w", err)
+	}
+	// Without the user the edit cannot be attributed, warned or reported; the previous author does not matter
+	if revisionData.Current.UserHidden {
+		return fmt.Errorf("revision %d: %w", revisionData.Current.Id, wikipedia.ErrUserHidden)
+	}
+	// Core only understands wikitext, JSON/Lua/CSS pages would be scored as garbage
+	for _, revision := range []wikipedia.Revision{revisionData.Current, revisionData.Previous} {
+		if revision.ContentModel != "" && revision.ContentModel != wikipedia.ContentModelWikitext {
+			return fmt.Errorf("revision %d is %s: %w", revision.Id, revision.ContentModel, errUnsupportedContentModel)
+		}
+	}
+	if revisionData.Current.Timestamp == 0 ||
+		revisionData.Current.Data == "" ||
+		revisionData.Previous.Timestamp == 0 ||
+		revisionData.Previous.Data == "" {
+		return fmt.Errorf("failed to get complete revision data: %w", wikipedia.ErrIncompleteData)
+	}
+
+	if protection := api.EditBlockedBy(revisionData.Current.Protection, time.Now().UTC()); protection != nil {
+		logger.Infof("Page is protected against the bot (%s, expires %s)", protection.Level, protection.Expiry)
+		change.Common.ProtectionLevel = protection.Level
+	}
+
+	change.Current = model.ProcessEventRevision{
+		Timestamp: revisionData.Current.Timestamp,
+		Text:      revisionData.Current.Data,
+		Id:        revisionData.Current.Id,
+		Username:  revisionData.Current.User,
+	}
+	change.Previous = model.ProcessEventRevision{
+		Timestamp: revisionData.Previous.Timestamp,
+		Text:      revisionData.Previous.Data,
+		Id:        revisionData.Previous.Id,
+		Username:  revisionData.Previous.User,
+	}
+	return nil
+}
+
+func LoadPageRevision(wg *sync.WaitGroup, api *wikipedia.WikipediaApi, batcher *wikipedia.RevisionBatcher, loadDiff bool, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
 	for change := range inChangeFeed {
@@ -23,31 +108,25 @@ func LoadPageRevision(wg *sync.WaitGroup, api *wikipedia.WikipediaApi, r *relay.
 			ctx, span := metrics.OtelTracer.Start(change.TraceContext, "LoadPageRevision")
 			defer span.End()
 
-			revisionData := api.GetRevision(logger, ctx, change.Common.Title, change.Current.Id)
-			if revisionData == nil ||
-				revisionData.Current.Timestamp == 0 ||
-				revisionData.Current.Data == "" ||
-				revisionData.Previous.Timestamp == 0 ||
-				revisionData.Previous.Data == "" {
-				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_revisions", "status": "failed"}).Inc()
-				logger.Error("failed to get complete revision data")
-				span.SetStatus(codes.Error, "failed to get complete revision data")
-				r.SendDebug(fmt.Sprintf("%v # Failed to get page revision", change.FormatIrcChange()))
-			} else {
-				change.Current = model.ProcessEventRevision{
-					Timestamp: revisionData.Current.Timestamp,
-					Text:      revisionData.Current.Data,
-					Id:        revisionData.Current.Id,
-					Username:  revisionData.Current.User,
-				}
-				change.Previous = model.ProcessEventRevision{
-					Timestamp: revisionData.Previous.Timestamp,
-					Text:      revisionData.Previous.Data,
-					Id:        revisionData.Previous.Id,
-					Username:  revisionData.Previous.User,
+			if err := loadPageRevision(logger, ctx, api, batcher, change); err != nil {
+				status := revisionLookupStatus(err)
+				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_revisions", "status": status}).Inc()
+				switch status {
+				case "suppressed", "text_hidden", "user_hidden", "content_model":
+					logger.Infof("Skipping change: %v", err)
+					r.SendDebug(fmt.Sprintf("%v # Skipped (%s)", change.FormatIrcChange(), status))
+					change.Complete(model.OutcomeSkipped)
+				default:
+					logger.Error(err.Error())
+					span.SetStatus(codes.Error, err.Error())
+					r.SendDebug(fmt.Sprintf("%v # Failed to get page revision", change.FormatIrcChange()))
+					change.Complete(model.OutcomeFailed)
 				}
-
+			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_page_revisions", "status": "success"}).Inc()
+				if loadDiff {
+					loadPageDiff(logger, ctx, api, change)
+				}
 				change.StartNewActiveSpan("pending.ProcessScoringChangeEvents")
 				outChangeFeed <- change
 			}
@@ -7,10 +7,20 @@ import (
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
 )
 
+func loadDistinctPagesCount(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	userDistinctPagesCount, err := db.Replica.GetUserDistinctPagesCount(logger, change.User.Username, change.ReceivedTime.Unix())
+	if err != nil {
+		return err
+	}
+	change.User.DistinctPages = userDistinctPagesCount
+	return nil
+}
+
 func LoadDistinctPagesCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -23,15 +33,14 @@ func LoadDistinctPagesCount(wg *sync.WaitGroup, db *database.DatabaseConnection,
 			_, span := metrics.OtelTracer.Start(change.TraceContext, "LoadDistinctPagesCount")
 			defer span.End()
 
-			userDistinctPagesCount, err := db.Replica.GetUserDistinctPagesCount(logger, change.User.Username)
-			if err != nil {
+			if err := loadDistinctPagesCount(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_user_distinct_count", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get user distinct pages count", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_user_distinct_count", "status": "passed"}).Inc()
-				change.User.DistinctPages = userDistinctPagesCount
 				change.StartNewActiveSpan("pending.LoadUserWarnsCount")
 				outChangeFeed <- change
 			}
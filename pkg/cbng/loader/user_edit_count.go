@@ -13,6 +13,22 @@ import (
 	"sync"
 )
 
+func loadUserEditCount(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	var f func(l *logrus.Entry, user string, before int64) (int64, error)
+	if net.ParseIP(change.User.Username) != nil {
+		f = db.Replica.GetAnonymousUserEditCount
+	} else {
+		f = db.Replica.GetRegisteredUserEditCount
+	}
+
+	userEditCount, err := f(logger, change.User.Username, change.ReceivedTime.Unix())
+	if err != nil {
+		return err
+	}
+	change.User.EditCount = userEditCount
+	return nil
+}
+
 func LoadUserEditCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -25,22 +41,14 @@ func LoadUserEditCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *r
 			_, span := metrics.OtelTracer.Start(change.TraceContext, "LoadUserEditCount")
 			defer span.End()
 
-			var f func(l *logrus.Entry, user string) (int64, error)
-			if net.ParseIP(change.User.Username) != nil {
-				f = db.Replica.GetAnonymousUserEditCount
-			} else {
-				f = db.Replica.GetRegisteredUserEditCount
-			}
-
-			userEditCount, err := f(logger, change.User.Username)
-			if err != nil {
+			if err := loadUserEditCount(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_anonymous_user_edit_count", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get user edit count", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_anonymous_user_edit_count", "status": "success"}).Inc()
-				change.User.EditCount = userEditCount
 				change.StartNewActiveSpan("pending.LoadUserRegistrationTime")
 				outChangeFeed <- change
 			}
@@ -7,10 +7,20 @@ import (
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
 )
 
+func loadUserRegistrationTime(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	userRegTime, err := db.Replica.GetUserRegistrationTime(logger, change.User.Username)
+	if err != nil {
+		return err
+	}
+	change.User.RegistrationTime = userRegTime
+	return nil
+}
+
 func LoadUserRegistrationTime(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -24,15 +34,14 @@ func LoadUserRegistrationTime(wg *sync.WaitGroup, db *database.DatabaseConnectio
 			_, span := metrics.OtelTracer.Start(change.TraceContext, "LoadUserRegistrationTime")
 			defer span.End()
 
-			userRegTime, err := db.Replica.GetUserRegistrationTime(logger, change.User.Username)
-			if err != nil {
+			if err := loadUserRegistrationTime(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_user_registration_time", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get user edit count", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_user_registration_time", "status": "success"}).Inc()
-				change.User.RegistrationTime = userRegTime
 				change.StartNewActiveSpan("pending.LoadDistinctPagesCount")
 				outChangeFeed <- change
 			}
@@ -7,10 +7,20 @@ import (
 	"github.com/cluebotng/botng/pkg/cbng/model"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"sync"
 )
 
+func loadUserWarnsCount(logger *logrus.Entry, db *database.DatabaseConnection, change *model.ProcessEvent) error {
+	userWarnCount, err := db.Replica.GetUserWarnCount(logger, change.User.Username, change.ReceivedTime.Unix())
+	if err != nil {
+		return err
+	}
+	change.User.Warns = userWarnCount
+	return nil
+}
+
 func LoadUserWarnsCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *relay.Relays, inChangeFeed, outChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
@@ -23,15 +33,14 @@ func LoadUserWarnsCount(wg *sync.WaitGroup, db *database.DatabaseConnection, r *
 			_, span := metrics.OtelTracer.Start(change.TraceContext, "LoadUserWarnsCount")
 			defer span.End()
 
-			userWarnCount, err := db.Replica.GetUserWarnCount(logger, change.User.Username)
-			if err != nil {
+			if err := loadUserWarnsCount(logger, db, change); err != nil {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_user_warning_count", "status": "failed"}).Inc()
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to get user warns count", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 			} else {
 				metrics.EditStatus.With(prometheus.Labels{"state": "lookup_user_warning_count", "status": "success"}).Inc()
-				change.User.Warns = userWarnCount
 				change.StartNewActiveSpan("pending.LoadPageRevision")
 				outChangeFeed <- change
 			}
@@ -44,6 +44,13 @@ var LoaderPageRevisionInUse prometheus.Gauge
 
 var ReplicaStats *prometheus.GaugeVec
 
+var WikipediaThrottled *prometheus.CounterVec
+var WikipediaBackoffSeconds prometheus.Counter
+var WikipediaReplicationLag prometheus.Gauge
+var WikipediaLogin *prometheus.CounterVec
+var WikipediaTokenCache *prometheus.CounterVec
+var WikipediaRevisionBatchSize prometheus.Histogram
+
 var OtelTracer trace.Tracer
 
 func init() {
@@ -53,6 +60,13 @@ func init() {
 	EditStatus = promauto.NewCounterVec(prometheus.CounterOpts{Name: "cbng_event_state"}, []string{"state", "status"})
 	RevertStatus = promauto.NewCounterVec(prometheus.CounterOpts{Name: "cbng_revert_state"}, []string{"state", "status", "meta"})
 
+	WikipediaThrottled = promauto.NewCounterVec(prometheus.CounterOpts{Name: "cbng_wikipedia_throttled"}, []string{"reason"})
+	WikipediaBackoffSeconds = promauto.NewCounter(prometheus.CounterOpts{Name: "cbng_wikipedia_backoff_seconds"})
+	WikipediaReplicationLag = promauto.NewGauge(prometheus.GaugeOpts{Name: "cbng_wikipedia_replication_lag_seconds"})
+	WikipediaLogin = promauto.NewCounterVec(prometheus.CounterOpts{Name: "cbng_wikipedia_login"}, []string{"status"})
+	WikipediaTokenCache = promauto.NewCounterVec(prometheus.CounterOpts{Name: "cbng_wikipedia_token_cache"}, []string{"status"})
+	WikipediaRevisionBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{Name: "cbng_wikipedia_revision_batch_size", Buckets: []float64{1, 2, 5, 10, 20, 50}})
+
 	PendingPageMetadataLoader = promauto.NewGauge(prometheus.GaugeOpts{Name: "cbng_loader", ConstLabels: prometheus.Labels{"status": "pending", "loader": "page_metadata"}})
 	PendingPageRecentEditCountLoader = promauto.NewGauge(prometheus.GaugeOpts{Name: "cbng_loader", ConstLabels: prometheus.Labels{"status": "pending", "loader": "page_recent_edit_count"}})
 	PendingPageRecentRevertCountLoader = promauto.NewGauge(prometheus.GaugeOpts{Name: "cbng_loader", ConstLabels: prometheus.Labels{"status": "pending", "loader": "page_recent_revert_count"}})
@@ -8,7 +8,9 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type ProcessEventCommon struct {
@@ -19,6 +21,7 @@ type ProcessEventCommon struct {
 	Creator            string
 	NumRecentEdits     int64
 	NumRecentRevisions int64
+	ProtectionLevel    string
 }
 
 type ProcessEventRevision struct {
@@ -28,6 +31,40 @@ type ProcessEventRevision struct {
 	Username  string
 }
 
+type ProcessEventDiff struct {
+	AddedLines        []string `json:"-"`
+	RemovedLines      []string `json:"-"`
+	Added             []string `json:"-"`
+	Removed           []string `json:"-"`
+	AddedLineCount    int64
+	RemovedLineCount  int64
+	AddedWords        int64
+	RemovedWords      int64
+	AddedCharacters   int64
+	RemovedCharacters int64
+}
+
+// NewProcessEventDiff counts words and characters from only the changed text, lines from the whole changed lines
+func NewProcessEventDiff(addedLines, removedLines, added, removed []string) *ProcessEventDiff {
+	diff := ProcessEventDiff{
+		AddedLines:       addedLines,
+		RemovedLines:     removedLines,
+		Added:            added,
+		Removed:          removed,
+		AddedLineCount:   int64(len(addedLines)),
+		RemovedLineCount: int64(len(removedLines)),
+	}
+	for _, text := range added {
+		diff.AddedWords += int64(len(strings.Fields(text)))
+		diff.AddedCharacters += int64(utf8.RuneCountInString(text))
+	}
+	for _, text := range removed {
+		diff.RemovedWords += int64(len(strings.Fields(text)))
+		diff.RemovedCharacters += int64(utf8.RuneCountInString(text))
+	}
+	return &diff
+}
+
 type ProcessEventUser struct {
 	Username         string
 	EditCount        int64
@@ -36,6 +73,19 @@ type ProcessEventUser struct {
 	RegistrationTime int64
 }
 
+type RevertRuleEvaluation struct {
+	Rule    string
+	Matched bool
+	Outcome string
+}
+
+// Outcomes passed to ProcessEvent.OnComplete when a change leaves the pipeline
+const (
+	OutcomeScored  = "scored"
+	OutcomeSkipped = "skipped"
+	OutcomeFailed  = "failed"
+)
+
 type ProcessEvent struct {
 	TraceContext   context.Context `json:"-"`
 	Logger         *logrus.Entry   `json:"-"`
@@ -50,8 +100,20 @@ type ProcessEvent struct {
 	Common         ProcessEventCommon
 	Current        ProcessEventRevision
 	Previous       ProcessEventRevision
+	Diff           *ProcessEventDiff
 	VandalismScore float64
 	RevertReason   string
+
+	RevertEvaluation []RevertRuleEvaluation
+
+	OnComplete func(outcome string) `json:"-"`
+}
+
+// Complete reports the outcome once the change will not be passed any further, OnComplete is only set for batch runs
+func (pe *ProcessEvent) Complete(outcome string) {
+	if pe.OnComplete != nil {
+		pe.OnComplete(outcome)
+	}
 }
 
 func (pe *ProcessEvent) EndActiveSpan() {
@@ -0,0 +1,194 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/exclusion"
+	"github.com/cluebotng/botng/pkg/cbng/helpers"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+type Condition func(e *Evaluation) (bool, error)
+
+type conditionBuilder func(params map[string]string) (Condition, error)
+
+var conditions = map[string]conditionBuilder{
+	"always":                      simpleCondition(func(e *Evaluation) bool { return true }),
+	"local_run_disabled":          simpleCondition(func(e *Evaluation) bool { return !e.Configuration.Bot.Run }),
+	"remote_run_disabled":         simpleCondition(func(e *Evaluation) bool { return !e.Configuration.Dynamic.Run }),
+	"angry_mode":                  simpleCondition(func(e *Evaluation) bool { return e.Configuration.Bot.Angry }),
+	"self_edit":                   simpleCondition(isSelfEdit),
+	"protected":                   simpleCondition(func(e *Evaluation) bool { return e.Change.Common.ProtectionLevel != "" }),
+	"exclusion":                   simpleCondition(isExcluded),
+	"user_is_creator":             simpleCondition(isUserCreator),
+	"tfa":                         simpleCondition(isTFA),
+	"angry_opt_in":                simpleCondition(isAngryOptIn),
+	"namespace":                   namespaceCondition,
+	"edit_count_above":            editCountAboveCondition,
+	"edit_count_warn_ratio_below": editCountWarnRatioBelowCondition,
+	"recent_revert":               recentRevertCondition,
+}
+
+func simpleCondition(f func(e *Evaluation) bool) conditionBuilder {
+	return func(params map[string]string) (Condition, error) {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("condition takes no params, got %v", params)
+		}
+		return func(e *Evaluation) (bool, error) { return f(e), nil }, nil
+	}
+}
+
+func intParam(params map[string]string, name string, fallback int64) (int64, error) {
+	value, ok := params[name]
+	if !ok {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %v", name, value, err)
+	}
+	return parsed, nil
+}
+
+func floatParam(params map[string]string, name string, fallback float64) (float64, error) {
+	value, ok := params[name]
+	if !ok {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %v", name, value, err)
+	}
+	return parsed, nil
+}
+
+func isSelfEdit(e *Evaluation) bool {
+	return e.Change.User.Username == e.Configuration.Wikipedia.Username
+}
+
+func isExcluded(e *Evaluation) bool {
+	// A template on either side of the edit counts, so removing {{nobots}} does not opt the page back in
+	botNames := []string{e.Configuration.Wikipedia.Username}
+	if template := exclusion.Check(e.Change.Current.Text, botNames, ""); template != nil {
+		e.Logger.Infof("Excluded by %s on current revision", template.Raw)
+		return true
+	}
+	if template := exclusion.Check(e.Change.Previous.Text, botNames, ""); template != nil {
+		e.Logger.Infof("Excluded by %s on previous revision", template.Raw)
+		return true
+	}
+	return false
+}
+
+func isUserCreator(e *Evaluation) bool {
+	return e.Change.User.Username == e.Change.Common.Creator
+}
+
+func isTFA(e *Evaluation) bool {
+	return e.Change.Common.Title == e.Configuration.Dynamic.TFA
+}
+
+func isAngryOptIn(e *Evaluation) bool {
+	return helpers.StringItemInSlice(e.Change.Common.Title, e.Configuration.Dynamic.AngryOptinPages)
+}
+
+func namespaceCondition(params map[string]string) (Condition, error) {
+	namespaceIds, err := helpers.ParseNamespaceIds(params["ids"])
+	if err != nil {
+		return nil, err
+	}
+	return func(e *Evaluation) (bool, error) {
+		return namespaceIds[e.Change.Common.NamespaceId], nil
+	}, nil
+}
+
+var warnRatioBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5}
+
+func warnRatioBucket(ratio float64) string {
+	for _, bucket := range warnRatioBuckets {
+		if ratio < bucket {
+			return fmt.Sprintf("lt_%v", bucket)
+		}
+	}
+	return fmt.Sprintf("gte_%v", warnRatioBuckets[len(warnRatioBuckets)-1])
+}
+
+// editCountThreshold uses the rule param when given, otherwise the bot configuration
+func editCountThreshold(e *Evaluation, edits *int64) int64 {
+	if edits != nil {
+		return *edits
+	}
+	return e.Configuration.Bot.EditCountThreshold
+}
+
+func optionalIntParam(params map[string]string, name string) (*int64, error) {
+	if _, ok := params[name]; !ok {
+		return nil, nil
+	}
+	value, err := intParam(params, name, 0)
+	return &value, err
+}
+
+func editCountAboveCondition(params map[string]string) (Condition, error) {
+	edits, err := optionalIntParam(params, "edits")
+	if err != nil {
+		return nil, err
+	}
+	return func(e *Evaluation) (bool, error) {
+		return e.Change.User.EditCount > editCountThreshold(e, edits), nil
+	}, nil
+}
+
+func editCountWarnRatioBelowCondition(params map[string]string) (Condition, error) {
+	edits, err := optionalIntParam(params, "edits")
+	if err != nil {
+		return nil, err
+	}
+	var ratio *float64
+	if _, ok := params["ratio"]; ok {
+		value, err := floatParam(params, "ratio", 0)
+		if err != nil {
+			return nil, err
+		}
+		ratio = &value
+	}
+	return func(e *Evaluation) (bool, error) {
+		if e.Change.User.EditCount <= 0 || e.Change.User.EditCount <= editCountThreshold(e, edits) {
+			return false, nil
+		}
+
+		ratioThreshold := e.Configuration.Bot.WarnRatioThreshold
+		if ratio != nil {
+			ratioThreshold = *ratio
+		}
+
+		userWarnRatio := float64(e.Change.User.Warns) / float64(e.Change.User.EditCount)
+		below := userWarnRatio < ratioThreshold
+		e.Logger.Debugf("User warn ratio: %f (threshold %f)", userWarnRatio, ratioThreshold)
+
+		status := "above"
+		if below {
+			status = "below"
+		}
+		metrics.RevertStatus.With(prometheus.Labels{"state": "edit_count_warn_ratio", "status": status, "meta": warnRatioBucket(userWarnRatio)}).Inc()
+		return below, nil
+	}, nil
+}
+
+func recentRevertCondition(params map[string]string) (Condition, error) {
+	threshold, err := intParam(params, "seconds", config.RecentRevertThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *Evaluation) (bool, error) {
+		// If we reverted this user/page before in the threshold, don't
+		lastRevertTime, err := e.Db.GetLastRevertTime(e.Logger, e.Ctx, e.Change.Common.Title, e.Change.User.Username)
+		if err != nil {
+			return false, err
+		}
+		return lastRevertTime != 0 && lastRevertTime > time.Now().UTC().Unix()-threshold, nil
+	}, nil
+}
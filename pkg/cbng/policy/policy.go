@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+const (
+	OutcomeRevert = "revert"
+	OutcomeSkip   = "skip"
+	OutcomeFail   = "fail"
+)
+
+var outcomeStatus = map[string]string{
+	OutcomeRevert: "success",
+	OutcomeSkip:   "skipped",
+	OutcomeFail:   "failed",
+}
+
+// Database is the bot state conditions may look up, implemented by the ClueBot database
+type Database interface {
+	GetLastRevertTime(l *logrus.Entry, ctx context.Context, title, user string) (int64, error)
+}
+
+// Evaluation is everything a condition may look at when deciding on a change
+type Evaluation struct {
+	Logger        *logrus.Entry
+	Ctx           context.Context
+	Configuration *config.Configuration
+	Db            Database
+	Change        *model.ProcessEvent
+}
+
+type Rule struct {
+	Name      string
+	Outcome   string
+	Reason    string
+	Metric    string
+	condition Condition
+}
+
+type Policy struct {
+	Rules []Rule
+}
+
+func NewPolicy(rules []config.RevertRuleConfiguration) (*Policy, error) {
+	policy := Policy{}
+	for i, ruleConfiguration := range rules {
+		if ruleConfiguration.Name == "" {
+			return nil, fmt.Errorf("rule %d: missing name", i)
+		}
+
+		if _, ok := outcomeStatus[ruleConfiguration.Outcome]; !ok {
+			return nil, fmt.Errorf("rule %s: unknown outcome '%s'", ruleConfiguration.Name, ruleConfiguration.Outcome)
+		}
+
+		builder, ok := conditions[ruleConfiguration.Condition]
+		if !ok {
+			return nil, fmt.Errorf("rule %s: unknown condition '%s'", ruleConfiguration.Name, ruleConfiguration.Condition)
+		}
+		condition, err := builder(ruleConfiguration.Params)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", ruleConfiguration.Name, err)
+		}
+
+		metric := ruleConfiguration.Metric
+		if metric == "" {
+			metric = ruleConfiguration.Name
+		}
+
+		policy.Rules = append(policy.Rules, Rule{
+			Name:      ruleConfiguration.Name,
+			Outcome:   ruleConfiguration.Outcome,
+			Reason:    ruleConfiguration.Reason,
+			Metric:    metric,
+			condition: condition,
+		})
+	}
+	return &policy, nil
+}
+
+// ForConfiguration returns the on-wiki policy when enabled and valid, otherwise the local one
+func ForConfiguration(logger *logrus.Entry, configuration *config.Configuration) (*Policy, error) {
+	return forOnWikiPolicy(logger, configuration, configuration.Dynamic.RevertPolicy.Load())
+}
+
+func forOnWikiPolicy(logger *logrus.Entry, configuration *config.Configuration, onWiki *config.OnWikiRevertPolicy) (*Policy, error) {
+	if configuration.Bot.OnWikiRevertPolicy && onWiki != nil && len(onWiki.Rules) > 0 {
+		policy, err := NewPolicy(onWiki.Rules)
+		if err == nil {
+			return policy, nil
+		}
+		logger.Errorf("Ignoring invalid on-wiki revert policy: %v", err)
+	}
+	return NewPolicy(configuration.Bot.RevertPolicy)
+}
+
+// Cache holds the policy for a configuration, only rebuilding it after the on-wiki policy has been reloaded
+type Cache struct {
+	mutex      sync.Mutex
+	built      bool
+	generation int64
+	policy     *Policy
+	err        error
+}
+
+func (c *Cache) Get(logger *logrus.Entry, configuration *config.Configuration) (*Policy, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// The rules and generation are read from the same snapshot, so a reload in between cannot be missed
+	onWiki := configuration.Dynamic.RevertPolicy.Load()
+	generation := int64(0)
+	if onWiki != nil {
+		generation = onWiki.Generation
+	}
+	if !c.built || c.generation != generation {
+		c.policy, c.err = forOnWikiPolicy(logger, configuration, onWiki)
+		c.generation = generation
+		c.built = true
+	}
+	return c.policy, c.err
+}
+
+// Evaluate runs the rules in order, the first matching rule decides the outcome
+func (p *Policy) Evaluate(e *Evaluation) bool {
+	change := e.Change
+	change.RevertReason = "Default Revert"
+	change.RevertEvaluation = []model.RevertRuleEvaluation{}
+
+	for _, rule := range p.Rules {
+		matched, err := rule.condition(e)
+		if err != nil {
+			e.Logger.Warnf("Not reverting due to error evaluating %s: %v", rule.Name, err)
+			change.RevertEvaluation = append(change.RevertEvaluation, model.RevertRuleEvaluation{Rule: rule.Name, Matched: true, Outcome: OutcomeFail})
+			change.RevertReason = fmt.Sprintf("Error evaluating %s", rule.Name)
+			metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": fmt.Sprintf("%s_error", rule.Metric)}).Inc()
+			return false
+		}
+
+		change.RevertEvaluation = append(change.RevertEvaluation, model.RevertRuleEvaluation{Rule: rule.Name, Matched: matched, Outcome: rule.Outcome})
+		if !matched {
+			continue
+		}
+
+		e.Logger.Infof("Rule %s matched with outcome %s: %s", rule.Name, rule.Outcome, rule.Reason)
+		change.RevertReason = rule.Reason
+		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": outcomeStatus[rule.Outcome], "meta": rule.Metric}).Inc()
+		return rule.Outcome == OutcomeRevert
+	}
+
+	e.Logger.Infof("Not reverting as no rule matched")
+	change.RevertReason = "No rule matched"
+	metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "skipped", "meta": "no_rule"}).Inc()
+	return false
+}
@@ -0,0 +1,332 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/sirupsen/logrus"
+	"testing"
+	"time"
+)
+
+type fakeDatabase struct {
+	lastRevertTime int64
+	err            error
+}
+
+func (f *fakeDatabase) GetLastRevertTime(l *logrus.Entry, ctx context.Context, title, user string) (int64, error) {
+	return f.lastRevertTime, f.err
+}
+
+func newEvaluation(configuration *config.Configuration, db Database, change *model.ProcessEvent) *Evaluation {
+	return &Evaluation{
+		Logger:        logrus.NewEntry(logrus.New()),
+		Ctx:           context.Background(),
+		Configuration: configuration,
+		Db:            db,
+		Change:        change,
+	}
+}
+
+func TestNewPolicyRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.RevertRuleConfiguration
+	}{
+		{"missing name", config.RevertRuleConfiguration{Condition: "always", Outcome: OutcomeRevert}},
+		{"unknown condition", config.RevertRuleConfiguration{Name: "rule", Condition: "no_such_condition", Outcome: OutcomeRevert}},
+		{"unknown outcome", config.RevertRuleConfiguration{Name: "rule", Condition: "always", Outcome: "maybe"}},
+		{"unexpected params", config.RevertRuleConfiguration{Name: "rule", Condition: "always", Outcome: OutcomeRevert, Params: map[string]string{"x": "1"}}},
+		{"invalid param", config.RevertRuleConfiguration{Name: "rule", Condition: "edit_count_above", Outcome: OutcomeRevert, Params: map[string]string{"edits": "many"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPolicy([]config.RevertRuleConfiguration{tt.rule}); err == nil {
+				t.Errorf("expected an error for %+v", tt.rule)
+			}
+		})
+	}
+}
+
+func TestDefaultRevertPolicyIsValid(t *testing.T) {
+	if _, err := NewPolicy(config.DefaultRevertPolicy(0.1)); err != nil {
+		t.Fatalf("default policy is invalid: %v", err)
+	}
+}
+
+func TestEvaluateFirstMatchWins(t *testing.T) {
+	rules := []config.RevertRuleConfiguration{
+		{Name: "talk", Condition: "namespace", Params: map[string]string{"ids": "1"}, Outcome: OutcomeSkip, Reason: "Talk page"},
+		{Name: "first", Condition: "always", Outcome: OutcomeRevert, Reason: "First"},
+		{Name: "second", Condition: "always", Outcome: OutcomeFail, Reason: "Second"},
+	}
+	policy, err := NewPolicy(rules)
+	if err != nil {
+		t.Fatalf("failed to build policy: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		namespaceId int64
+		revert      bool
+		reason      string
+		evaluated   []model.RevertRuleEvaluation
+	}{
+		{
+			name:        "earlier rule matches",
+			namespaceId: 1,
+			revert:      false,
+			reason:      "Talk page",
+			evaluated:   []model.RevertRuleEvaluation{{Rule: "talk", Matched: true, Outcome: OutcomeSkip}},
+		},
+		{
+			name:        "later rules are not evaluated",
+			namespaceId: 0,
+			revert:      true,
+			reason:      "First",
+			evaluated: []model.RevertRuleEvaluation{
+				{Rule: "talk", Matched: false, Outcome: OutcomeSkip},
+				{Rule: "first", Matched: true, Outcome: OutcomeRevert},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := &model.ProcessEvent{Common: model.ProcessEventCommon{NamespaceId: tt.namespaceId}}
+			revert := policy.Evaluate(newEvaluation(&config.Configuration{}, &fakeDatabase{}, change))
+			if revert != tt.revert {
+				t.Errorf("expected revert=%v, got %v", tt.revert, revert)
+			}
+			if change.RevertReason != tt.reason {
+				t.Errorf("expected reason %q, got %q", tt.reason, change.RevertReason)
+			}
+			if len(change.RevertEvaluation) != len(tt.evaluated) {
+				t.Fatalf("expected %d evaluations, got %+v", len(tt.evaluated), change.RevertEvaluation)
+			}
+			for i, evaluation := range tt.evaluated {
+				if change.RevertEvaluation[i] != evaluation {
+					t.Errorf("evaluation %d: expected %+v, got %+v", i, evaluation, change.RevertEvaluation[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateNoRuleMatched(t *testing.T) {
+	policy, err := NewPolicy([]config.RevertRuleConfiguration{
+		{Name: "talk", Condition: "namespace", Params: map[string]string{"ids": "1"}, Outcome: OutcomeRevert, Reason: "Talk page"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build policy: %v", err)
+	}
+
+	change := &model.ProcessEvent{}
+	if policy.Evaluate(newEvaluation(&config.Configuration{}, &fakeDatabase{}, change)) {
+		t.Errorf("expected no revert when no rule matched")
+	}
+	if change.RevertReason != "No rule matched" {
+		t.Errorf("unexpected reason %q", change.RevertReason)
+	}
+}
+
+func TestEvaluateRecentRevert(t *testing.T) {
+	policy, err := NewPolicy([]config.RevertRuleConfiguration{
+		{Name: "recent_revert", Condition: "recent_revert", Params: map[string]string{"seconds": "3600"}, Outcome: OutcomeSkip, Reason: "Reverted before"},
+		{Name: "fallback", Condition: "always", Outcome: OutcomeRevert, Reason: "Default Revert"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build policy: %v", err)
+	}
+
+	now := time.Now().UTC().Unix()
+	tests := []struct {
+		name   string
+		db     *fakeDatabase
+		revert bool
+		reason string
+	}{
+		{"never reverted", &fakeDatabase{}, true, "Default Revert"},
+		{"reverted within threshold", &fakeDatabase{lastRevertTime: now - 60}, false, "Reverted before"},
+		{"reverted outside threshold", &fakeDatabase{lastRevertTime: now - 7200}, true, "Default Revert"},
+		{"lookup failed", &fakeDatabase{err: errors.New("connection refused")}, false, "Error evaluating recent_revert"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := &model.ProcessEvent{}
+			revert := policy.Evaluate(newEvaluation(&config.Configuration{}, tt.db, change))
+			if revert != tt.revert {
+				t.Errorf("expected revert=%v, got %v", tt.revert, revert)
+			}
+			if change.RevertReason != tt.reason {
+				t.Errorf("expected reason %q, got %q", tt.reason, change.RevertReason)
+			}
+		})
+	}
+}
+
+func TestForConfigurationFallsBackToLocal(t *testing.T) {
+	local := []config.RevertRuleConfiguration{{Name: "local", Condition: "always", Outcome: OutcomeRevert}}
+	onWiki := []config.RevertRuleConfiguration{{Name: "on_wiki", Condition: "always", Outcome: OutcomeSkip}}
+	invalid := []config.RevertRuleConfiguration{{Name: "on_wiki", Condition: "no_such_condition", Outcome: OutcomeSkip}}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		dynamic []config.RevertRuleConfiguration
+		rule    string
+	}{
+		{"disabled", false, onWiki, "local"},
+		{"enabled", true, onWiki, "on_wiki"},
+		{"enabled but empty", true, nil, "local"},
+		{"enabled but invalid", true, invalid, "local"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := &config.Configuration{}
+			configuration.Bot.RevertPolicy = local
+			configuration.Bot.OnWikiRevertPolicy = tt.enabled
+			configuration.Dynamic.RevertPolicy.Store(&config.OnWikiRevertPolicy{Rules: tt.dynamic, Generation: 1})
+
+			policy, err := ForConfiguration(logrus.NewEntry(logrus.New()), configuration)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(policy.Rules) != 1 || policy.Rules[0].Name != tt.rule {
+				t.Errorf("expected rule %s, got %+v", tt.rule, policy.Rules)
+			}
+		})
+	}
+}
+
+func TestCacheRebuildsOnReload(t *testing.T) {
+	configuration := &config.Configuration{}
+	configuration.Bot.OnWikiRevertPolicy = true
+	configuration.Dynamic.RevertPolicy.Store(&config.OnWikiRevertPolicy{
+		Rules:      []config.RevertRuleConfiguration{{Name: "first", Condition: "always", Outcome: OutcomeRevert}},
+		Generation: 1,
+	})
+
+	cache := Cache{}
+	logger := logrus.NewEntry(logrus.New())
+	first, err := cache.Get(logger, configuration)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cached, _ := cache.Get(logger, configuration); cached != first {
+		t.Errorf("expected the cached policy to be reused until the generation changes")
+	}
+
+	configuration.Dynamic.RevertPolicy.Store(&config.OnWikiRevertPolicy{
+		Rules:      []config.RevertRuleConfiguration{{Name: "second", Condition: "always", Outcome: OutcomeRevert}},
+		Generation: 2,
+	})
+	reloaded, err := cache.Get(logger, configuration)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reloaded.Rules[0].Name != "second" {
+		t.Errorf("expected the reloaded policy, got %+v", reloaded.Rules)
+	}
+}
+
+func TestCacheWithoutOnWikiPolicy(t *testing.T) {
+	configuration := &config.Configuration{}
+	configuration.Bot.OnWikiRevertPolicy = true
+	configuration.Bot.RevertPolicy = []config.RevertRuleConfiguration{{Name: "local", Condition: "always", Outcome: OutcomeRevert}}
+
+	cache := Cache{}
+	policy, err := cache.Get(logrus.NewEntry(logrus.New()), configuration)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if policy.Rules[0].Name != "local" {
+		t.Errorf("expected the local policy before the on-wiki policy is loaded, got %+v", policy.Rules)
+	}
+}
+
+func TestExclusionChecksBothRevisions(t *testing.T) {
+	configuration := &config.Configuration{}
+	configuration.Wikipedia.Username = "ClueBot NG"
+
+	tests := []struct {
+		name     string
+		current  string
+		previous string
+		excluded bool
+	}{
+		{"neither revision", "text", "text", false},
+		{"current revision", "{{nobots}} text", "text", true},
+		{"only previous revision", "text", "{{bots|deny=ClueBot NG}} text", true},
+		{"previous revision allows", "text", "{{bots|deny=SineBot}} text", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := &model.ProcessEvent{
+				Current:  model.ProcessEventRevision{Text: tt.current},
+				Previous: model.ProcessEventRevision{Text: tt.previous},
+			}
+			if excluded := isExcluded(newEvaluation(configuration, &fakeDatabase{}, change)); excluded != tt.excluded {
+				t.Errorf("expected excluded=%v, got %v", tt.excluded, excluded)
+			}
+		})
+	}
+}
+
+func TestEditCountWarnRatioBelow(t *testing.T) {
+	configuration := &config.Configuration{}
+	configuration.Bot.EditCountThreshold = 50
+	configuration.Bot.WarnRatioThreshold = 0.1
+
+	tests := []struct {
+		name      string
+		params    map[string]string
+		editCount int64
+		warns     int64
+		below     bool
+	}{
+		{"no edits", nil, 0, 0, false},
+		{"no edits with warnings", nil, 0, 3, false},
+		{"at edit count threshold", nil, 50, 0, false},
+		{"warns equal to ratio", nil, 100, 10, false},
+		{"warns just under ratio", nil, 100, 9, true},
+		{"warns just over ratio", nil, 100, 11, false},
+		{"no warnings", nil, 51, 0, true},
+		{"custom edits below default threshold", map[string]string{"edits": "10"}, 20, 1, true},
+		{"custom edits at threshold", map[string]string{"edits": "10"}, 10, 0, false},
+		{"custom ratio allows more warnings", map[string]string{"ratio": "0.5"}, 100, 40, true},
+		{"custom ratio allows fewer warnings", map[string]string{"ratio": "0.01"}, 100, 1, false},
+		{"custom edits and ratio", map[string]string{"edits": "5", "ratio": "0.2"}, 10, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, err := editCountWarnRatioBelowCondition(tt.params)
+			if err != nil {
+				t.Fatalf("failed to build condition: %v", err)
+			}
+
+			change := &model.ProcessEvent{User: model.ProcessEventUser{EditCount: tt.editCount, Warns: tt.warns}}
+			below, err := condition(newEvaluation(configuration, &fakeDatabase{}, change))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if below != tt.below {
+				t.Errorf("expected below=%v for %d warns in %d edits, got %v", tt.below, tt.warns, tt.editCount, below)
+			}
+		})
+	}
+}
+
+func TestEditCountWarnRatioBelowInvalidParams(t *testing.T) {
+	for _, params := range []map[string]string{{"edits": "lots"}, {"ratio": "tenth"}} {
+		if _, err := editCountWarnRatioBelowCondition(params); err == nil {
+			t.Errorf("expected an error for %v", params)
+		}
+	}
+}
@@ -16,67 +16,7 @@ import (
 )
 
 func generateXML(pe *model.ProcessEvent) ([]byte, error) {
-	type WPEditCommon struct {
-		PageMadeTime         int64  `xml:"page_made_time"`
-		Title                string `xml:"title"`
-		Namespace            string `xml:"namespace"`
-		Creator              string `xml:"creator"`
-		NumerOfRecentEdits   int64  `xml:"num_recent_edits"`
-		NumerOfRecentReverts int64  `xml:"num_recent_reversions"`
-	}
-	type WPEditRevision struct {
-		Timestamp int64  `xml:"timestamp"`
-		Text      string `xml:"text"`
-	}
-	type WPEdit struct {
-		EditType               string         `xml:"EditType"`
-		EditId                 int64          `xml:"EditID"`
-		Comment                string         `xml:"comment"`
-		User                   string         `xml:"user"`
-		UserEditCount          int64          `xml:"user_edit_count"`
-		UserDistinctPagesCount int64          `xml:"user_distinct_pages"`
-		UserWarningsCount      int64          `xml:"user_warns"`
-		PreviousUser           string         `xml:"prev_user"`
-		UserRegistrationTime   int64          `xml:"user_reg_time"`
-		Common                 WPEditCommon   `xml:"common"`
-		Current                WPEditRevision `xml:"current"`
-		Previous               WPEditRevision `xml:"previous"`
-	}
-	type WPEditSet struct {
-		WPEdit WPEdit
-	}
-
-	data := WPEditSet{
-		WPEdit: WPEdit{
-			EditType:               "change",
-			EditId:                 pe.Current.Id,
-			Comment:                pe.Comment,
-			User:                   pe.User.Username,
-			UserEditCount:          pe.User.EditCount,
-			UserDistinctPagesCount: pe.User.DistinctPages,
-			UserWarningsCount:      pe.User.Warns,
-			PreviousUser:           pe.Previous.Username,
-			UserRegistrationTime:   pe.User.RegistrationTime,
-			Common: WPEditCommon{
-				PageMadeTime:         pe.Common.PageMadeTime,
-				Title:                pe.Common.Title,
-				Namespace:            pe.Common.Namespace,
-				Creator:              pe.Common.Creator,
-				NumerOfRecentEdits:   pe.Common.NumRecentEdits,
-				NumerOfRecentReverts: pe.Common.NumRecentRevisions,
-			},
-			Current: WPEditRevision{
-				Text:      pe.Current.Text,
-				Timestamp: pe.Current.Timestamp,
-			},
-			Previous: WPEditRevision{
-				Text:      pe.Previous.Text,
-				Timestamp: pe.Previous.Timestamp,
-			},
-		},
-	}
-
-	return xml.Marshal(data)
+	return xml.Marshal(model.WPEditSet{WPEdit: []model.WPEdit{model.NewWPEdit(pe)}})
 }
 
 func isVandalism(l *logrus.Entry, parentCtx context.Context, configuration *config.Configuration, pe *model.ProcessEvent) (bool, error) {
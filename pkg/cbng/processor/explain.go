@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/database"
+	"github.com/cluebotng/botng/pkg/cbng/feed"
+	"github.com/cluebotng/botng/pkg/cbng/helpers"
+	"github.com/cluebotng/botng/pkg/cbng/loader"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"github.com/sirupsen/logrus"
+	"io"
+	"time"
+)
+
+type ExplainReport struct {
+	Change       *model.ProcessEvent
+	ScoringError error
+	IsVandalism  bool
+	Whitelisted  bool
+	ShouldRevert bool
+	Decision     string
+	Warning      *wikipedia.WarningLevel
+}
+
+// EvaluateChange runs a change through the loaders, scoring and revert rules without taking any action
+func EvaluateChange(l *logrus.Entry, parentCtx context.Context, configuration *config.Configuration, db *database.DatabaseConnection, api *wikipedia.WikipediaApi, change *model.ProcessEvent) (*ExplainReport, error) {
+	logger := l.WithField("function", "processor.EvaluateChange")
+	ctx, span := metrics.OtelTracer.Start(parentCtx, "processor.EvaluateChange")
+	defer span.End()
+
+	if err := loader.LoadChange(logger, ctx, db, api, change); err != nil {
+		return nil, err
+	}
+
+	report := ExplainReport{Change: change}
+	report.IsVandalism, report.ScoringError = isVandalism(logger, ctx, configuration, change)
+	report.Whitelisted = isWhitelisted(logger, configuration, change.User.Username)
+	report.ShouldRevert = shouldRevert(logger, ctx, configuration, db, change)
+
+	switch {
+	case report.ScoringError != nil:
+		report.Decision = "Not reverted (failed to score)"
+	case !report.IsVandalism:
+		report.Decision = "Not reverted (not vandalism)"
+	case report.Whitelisted:
+		report.Decision = "Not reverted (user is whitelisted)"
+	case !report.ShouldRevert:
+		report.Decision = fmt.Sprintf("Not reverted (%s)", change.RevertReason)
+	default:
+		report.Decision = fmt.Sprintf("Reverted (%s)", change.RevertReason)
+	}
+	return &report, nil
+}
+
+func Explain(l *logrus.Entry, configuration *config.Configuration, db *database.DatabaseConnection, api *wikipedia.WikipediaApi, revisionId int64, w io.Writer) error {
+	logger := l.WithField("function", "processor.Explain")
+
+	change, err := feed.NewChangeFromRevisionId(logger, api, revisionId)
+	if err != nil {
+		return err
+	}
+
+	report, err := EvaluateChange(change.Logger, change.TraceContext, configuration, db, api, change)
+	if err != nil {
+		return err
+	}
+	warning, err := api.GetWarningLevel(logger, change.TraceContext, change.User.Username, configuration.Bot.WarningWindow)
+	if err != nil {
+		return err
+	}
+	report.Warning = &warning
+	report.Write(w)
+	return nil
+}
+
+func (r *ExplainReport) Write(w io.Writer) {
+	change := r.Change
+	row := func(name string, value interface{}) {
+		_, _ = fmt.Fprintf(w, "  %-24s %v\n", name, value)
+	}
+	timestamp := func(value int64) string {
+		if value == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%d (%s)", value, time.Unix(value, 0).UTC().Format(time.RFC3339))
+	}
+
+	_, _ = fmt.Fprintf(w, "Revision %d of [[%s]]\n", change.Current.Id, change.TitleWithNamespace())
+	row("diff", change.GetDiffUrl())
+	row("user", change.User.Username)
+	row("comment", change.Comment)
+	row("length", helpers.FormatPlusOrMinus(change.Length))
+
+	_, _ = fmt.Fprintln(w, "\nFeatures")
+	row("user_edit_count", change.User.EditCount)
+	row("user_distinct_pages", change.User.DistinctPages)
+	row("user_warns", change.User.Warns)
+	row("user_reg_time", change.User.RegistrationTime)
+	row("prev_user", change.Previous.Username)
+	row("page_made_time", change.Common.PageMadeTime)
+	row("creator", change.Common.Creator)
+	row("namespace", change.Common.Namespace)
+	row("num_recent_edits", change.Common.NumRecentEdits)
+	row("num_recent_reversions", change.Common.NumRecentRevisions)
+	row("protection_level", change.Common.ProtectionLevel)
+	row("current_timestamp", timestamp(change.Current.Timestamp))
+	row("current_text_length", len(change.Current.Text))
+	row("previous_timestamp", timestamp(change.Previous.Timestamp))
+	row("previous_text_length", len(change.Previous.Text))
+
+	if change.Diff != nil {
+		_, _ = fmt.Fprintln(w, "\nDiff")
+		row("lines", fmt.Sprintf("+%d -%d", change.Diff.AddedLineCount, change.Diff.RemovedLineCount))
+		row("words", fmt.Sprintf("+%d -%d", change.Diff.AddedWords, change.Diff.RemovedWords))
+		row("characters", fmt.Sprintf("+%d -%d", change.Diff.AddedCharacters, change.Diff.RemovedCharacters))
+		for _, line := range change.Diff.RemovedLines {
+			_, _ = fmt.Fprintf(w, "  - %s\n", line)
+		}
+		for _, line := range change.Diff.AddedLines {
+			_, _ = fmt.Fprintf(w, "  + %s\n", line)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "\nScoring")
+	if r.ScoringError != nil {
+		row("error", r.ScoringError)
+	} else {
+		row("score", change.VandalismScore)
+		row("vandalism", r.IsVandalism)
+	}
+	row("whitelisted", r.Whitelisted)
+
+	_, _ = fmt.Fprintln(w, "\nRevert rules")
+	for i, evaluation := range change.RevertEvaluation {
+		result := "pass"
+		if evaluation.Matched {
+			result = fmt.Sprintf("matched (%s)", evaluation.Outcome)
+		}
+		_, _ = fmt.Fprintf(w, "  %2d. %-24s %s\n", i+1, evaluation.Rule, result)
+	}
+	row("should revert", fmt.Sprintf("%v (%s)", r.ShouldRevert, change.RevertReason))
+
+	if r.Warning != nil {
+		_, _ = fmt.Fprintln(w, "\nWarnings")
+		if r.Warning.Level == 0 {
+			row("current level", 0)
+		} else {
+			row("current level", fmt.Sprintf("%d (%s at %s)", r.Warning.Level, r.Warning.Template, r.Warning.Time.Format(time.RFC3339)))
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\nDecision: %s\n", r.Decision)
+}
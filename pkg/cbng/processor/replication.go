@@ -34,11 +34,13 @@ func ReplicationWatcher(wg *sync.WaitGroup, configuration *config.Configuration,
 					defer metrics.ProcessorsReplicationWatcherInUse.Dec()
 
 					var replicationPoint int64
+					replicationKnown := true
 					if !ignoreReplicationDelay {
 						var err error
 						if replicationPoint, err = db.Replica.GetLatestChangeTimestamp(logger); err != nil {
+							// Nothing can be released, but pending changes must still expire so they are not held forever
 							logger.Warnf("Failed to get current replication point: %+v", err)
-							return
+							replicationKnown = false
 						}
 					}
 
@@ -46,7 +48,7 @@ func ReplicationWatcher(wg *sync.WaitGroup, configuration *config.Configuration,
 						logger := change.Logger.WithField("function", "processor.ReplicationWatcher")
 						func() {
 							// If we're ignoring replication or are past the change in replication, kick off the process
-							if ignoreReplicationDelay || change.ChangeTime.Unix() >= replicationPoint {
+							if ignoreReplicationDelay || (replicationKnown && change.ChangeTime.Unix() >= replicationPoint) {
 								logger.Tracef("Change %v past replication point %v while pending (%v)", change.Uuid, replicationPoint, ignoreReplicationDelay)
 								metrics.EditStatus.With(prometheus.Labels{"state": "wait_for_replication", "status": "success"}).Inc()
 								metrics.ReplicationWatcherSuccess.Inc()
@@ -64,6 +66,7 @@ func ReplicationWatcher(wg *sync.WaitGroup, configuration *config.Configuration,
 								metrics.ReplicationWatcherTimout.Inc()
 
 								change.EndActiveSpanInError(codes.Error, "Timeout while waiting for replication")
+								change.Complete(model.OutcomeFailed)
 								delete(pending, change.Uuid)
 								return
 							}
@@ -98,6 +101,10 @@ func ReplicationWatcher(wg *sync.WaitGroup, configuration *config.Configuration,
 				logger.Infof("Triggering TFA reload")
 				configuration.Instances.TFA.TriggerReload()
 			}
+			if configuration.Instances.RevertPolicy != nil && change.Common.Title == configuration.Instances.RevertPolicy.GetPageName() {
+				logger.Infof("Triggering Revert Policy reload")
+				configuration.Instances.RevertPolicy.TriggerReload()
+			}
 		}
 	}
 }
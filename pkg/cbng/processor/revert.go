@@ -2,24 +2,81 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/aiv"
 	"github.com/cluebotng/botng/pkg/cbng/config"
 	"github.com/cluebotng/botng/pkg/cbng/database"
+	"github.com/cluebotng/botng/pkg/cbng/exclusion"
 	"github.com/cluebotng/botng/pkg/cbng/helpers"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
 	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/policy"
 	"github.com/cluebotng/botng/pkg/cbng/relay"
+	"github.com/cluebotng/botng/pkg/cbng/warning"
 	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 )
 
-func revertChange(l *logrus.Entry, parentCtx context.Context, api *wikipedia.WikipediaApi, change *model.ProcessEvent, configuration *config.Configuration, mysqlVandalismId int64) bool {
+const (
+	topRevisionCurrent         = "current"
+	topRevisionVandalNewerEdit = "vandal_newer_edit"
+	topRevisionSelfReverted    = "self_reverted"
+	topRevisionBeaten          = "beaten"
+	topRevisionNewerEdit       = "newer_edit"
+	revertTitleBlacklisted     = "title_blacklisted"
+)
+
+// apiErrorStatus maps a Wikipedia client error to a metric label, so protection and blocks are visible separately
+func apiErrorStatus(err error, fallback string) string {
+	switch {
+	case errors.Is(err, wikipedia.ErrTitleBlacklisted):
+		return revertTitleBlacklisted
+	case errors.Is(err, wikipedia.ErrProtected):
+		return "protected"
+	case errors.Is(err, wikipedia.ErrBlocked):
+		return "blocked"
+	case errors.Is(err, wikipedia.ErrAlreadyRolled):
+		return "already_rolled"
+	case errors.Is(err, wikipedia.ErrEditConflict):
+		return "edit_conflict"
+	case errors.Is(err, wikipedia.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, wikipedia.ErrNotFound):
+		return "not_found"
+	}
+	return fallback
+}
+
+type revertResult struct {
+	Reverted bool
+	State    string
+	Top      *wikipedia.Revision
+}
+
+// classifyTopRevision works out what happened to the page since the vandalism, revertRevision is the rollback target
+func classifyTopRevision(change *model.ProcessEvent, revertRevision, top *wikipedia.Revision) string {
+	restored := top.Sha1 != "" && top.Sha1 == revertRevision.Sha1
+	switch {
+	case top.Id == change.Current.Id:
+		return topRevisionCurrent
+	case top.User == change.User.Username && restored:
+		return topRevisionSelfReverted
+	case top.User == change.User.Username:
+		return topRevisionVandalNewerEdit
+	case restored:
+		return topRevisionBeaten
+	default:
+		return topRevisionNewerEdit
+	}
+}
+
+func revertChange(l *logrus.Entry, parentCtx context.Context, api *wikipedia.WikipediaApi, change *model.ProcessEvent, configuration *config.Configuration, mysqlVandalismId int64) revertResult {
 	logger := l.WithFields(logrus.Fields{
 		"function": "processor.revertChange",
 		"args": map[string]interface{}{
@@ -29,8 +86,15 @@ func revertChange(l *logrus.Entry, parentCtx context.Context, api *wikipedia.Wik
 	ctx, span := metrics.OtelTracer.Start(parentCtx, "revert.revertChange")
 	defer span.End()
 
+	revisionHistory, err := api.GetRevisionHistory(logger, ctx, change.Common.Title, change.Current.Id)
+	if err != nil {
+		logger.Warnf("Failed to lookup revision history: %v", err)
+		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "failed", "meta": apiErrorStatus(err, "lookup_revision")}).Inc()
+		return revertResult{}
+	}
+
 	var revertRevision *wikipedia.Revision
-	for _, revision := range *api.GetRevisionHistory(logger, ctx, change.Common.Title, change.Current.Id) {
+	for _, revision := range revisionHistory {
 		if revision.User != change.User.Username {
 			revertRevision = &revision
 			break
@@ -39,13 +103,13 @@ func revertChange(l *logrus.Entry, parentCtx context.Context, api *wikipedia.Wik
 	if revertRevision == nil {
 		logger.Infof("Failed to find revert revision")
 		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "failed", "meta": "lookup_revision"}).Inc()
-		return false
+		return revertResult{}
 	}
 
 	if change.User.Username == configuration.Wikipedia.Username || helpers.StringItemInSlice(change.User.Username, configuration.Bot.Friends) {
 		logger.Infof("Revert revision is self or a friend: %v", revertRevision)
 		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "failed", "meta": "revision_is_friend"}).Inc()
-		return false
+		return revertResult{}
 	}
 
 	revComment := "older version"
@@ -61,16 +125,70 @@ func revertChange(l *logrus.Entry, parentCtx context.Context, api *wikipedia.Wik
 		mysqlVandalismId,
 	)
 
-	if !api.Rollback(logger, ctx, helpers.PageTitle(change.Common.Namespace, change.Common.Title), change.User.Username, comment) {
-		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "failed", "meta": "api"}).Inc()
-		return false
+	title := helpers.PageTitle(change.Common.Namespace, change.Common.Title)
+	top, err := api.GetTopRevision(logger, ctx, title)
+	if err != nil {
+		logger.Warnf("Failed to lookup top revision: %v", err)
+		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "failed", "meta": "lookup_top_revision"}).Inc()
+		return revertResult{}
+	}
+
+	state := classifyTopRevision(change, revertRevision, top)
+	logger.Infof("Top revision is %d by %s (%s)", top.Id, top.User, state)
+	if state != topRevisionCurrent && state != topRevisionVandalNewerEdit {
+		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "skipped", "meta": state}).Inc()
+		return revertResult{State: state, Top: top}
+	}
+
+	if err := api.Rollback(logger, ctx, title, change.User.Username, comment); err != nil {
+		logger.Warnf("Failed to rollback: %v", err)
+
+		// Only known once rollback refuses, checking up front would cost a request on every revert
+		if errors.Is(err, wikipedia.ErrTitleBlacklisted) {
+			metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "skipped", "meta": revertTitleBlacklisted}).Inc()
+			return revertResult{State: revertTitleBlacklisted}
+		}
+
+		// Lost a race between the check and the rollback, see who got there first
+		if top, topErr := api.GetTopRevision(logger, ctx, title); topErr == nil {
+			state := classifyTopRevision(change, revertRevision, top)
+			if state != topRevisionCurrent && state != topRevisionVandalNewerEdit {
+				logger.Infof("Top revision is now %d by %s (%s)", top.Id, top.User, state)
+				metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "skipped", "meta": state}).Inc()
+				return revertResult{State: state, Top: top}
+			}
+		}
+
+		// The vandalism is still live, so this is a genuine failure rather than a race
+		metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "failed", "meta": apiErrorStatus(err, "api")}).Inc()
+		return revertResult{}
 	}
 
-	metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "success", "meta": ""}).Inc()
-	return true
+	metrics.RevertStatus.With(prometheus.Labels{"state": "revert", "status": "success", "meta": state}).Inc()
+	return revertResult{Reverted: true, State: state, Top: top}
 }
 
-func doWarn(l *logrus.Entry, parentCtx context.Context, api *wikipedia.WikipediaApi, r *relay.Relays, change *model.ProcessEvent, configuration *config.Configuration, mysqlVandalismId int64) bool {
+var warningSelectors = warning.Cache{}
+
+func selectWarningTemplate(logger *logrus.Entry, change *model.ProcessEvent, configuration *config.Configuration, values warning.Values) *warning.Selection {
+	fallback := warning.Expand("fallback", "User:{bot}/Warnings/Warning", config.ClueBotWarningArguments, values)
+
+	selector, err := warningSelectors.Get(configuration.Bot.WarningTemplates)
+	if err != nil {
+		logger.Errorf("Failed to build warning selector: %v", err)
+		return fallback
+	}
+	selection := selector.Select(change, values)
+	if selection == nil {
+		logger.Warnf("No warning template matched, using %s", fallback.Template)
+		return fallback
+	}
+	logger.Infof("Selected %s warning template: %s", selection.Name, selection.Template)
+	metrics.EditStatus.With(prometheus.Labels{"state": "warning_template", "status": selection.Name}).Inc()
+	return selection
+}
+
+func doWarn(l *logrus.Entry, parentCtx context.Context, api *wikipedia.WikipediaApi, db *database.DatabaseConnection, r *relay.Relays, change *model.ProcessEvent, configuration *config.Configuration, mysqlVandalismId int64) bool {
 	logger := l.WithFields(logrus.Fields{
 		"function": "processor.doWarn",
 		"args": map[string]interface{}{
@@ -81,7 +199,7 @@ func doWarn(l *logrus.Entry, parentCtx context.Context, api *wikipedia.Wikipedia
 	defer span.End()
 
 	report := fmt.Sprintf("[[%s]] was [%s changed] by [[Special:Contributions/%s|%s]] [[User:%s|(u)]] [[User talk:%s|(t)]] ANN scored at %f on %s",
-		strings.ReplaceAll("File:", ":File:", change.TitleWithNamespace()),
+		strings.ReplaceAll(change.TitleWithNamespace(), "File:", ":File:"),
 		change.GetDiffUrl(),
 		change.User.Username, change.User.Username,
 		change.User.Username,
@@ -90,47 +208,87 @@ func doWarn(l *logrus.Entry, parentCtx context.Context, api *wikipedia.Wikipedia
 		time.Now().Format(time.RFC3339),
 	)
 
-	warningLevel := api.GetWarningLevel(logger, ctx, change.User.Username)
-	logger.Infof("Found current warning level for user: %v", warningLevel)
-	if warningLevel >= 4 {
-		page := api.GetPage(logger, ctx, "Wikipedia:Administrator_intervention_against_vandalism/TB2")
-		if page == nil {
-			logger.Warnf("Failed to fetch current AIV")
-			metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "failed"}).Inc()
-			return false
-		}
-		if strings.Contains(page.Data, change.User.Username) {
-			logger.Infof("User already reported to AIV")
-			metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "skipped"}).Inc()
-			return false
-		}
+	block, err := api.GetUserBlock(logger, ctx, change.User.Username)
+	if err != nil {
+		logger.Warnf("Failed to check for existing block, continuing: %v", err)
+	} else if block != nil {
+		logger.Infof("User already blocked as %s by %s (expires %s), not warning or reporting", block.User, block.By, block.Expiry)
+		metrics.EditStatus.With(prometheus.Labels{"state": "warn", "status": "blocked"}).Inc()
+		return false
+	}
 
-		notice := fmt.Sprintf("* {{%s|%s}} - %s (Automated) ~~~~",
-			helpers.AivUserVandalType(change.User.Username),
-			change.User.Username,
-			report,
+	talkPageText := ""
+	talkPage, err := api.GetPage(logger, ctx, fmt.Sprintf("User talk:%s", change.User.Username))
+	if err == nil {
+		talkPageText = talkPage.Data
+	} else if !errors.Is(err, wikipedia.ErrNotFound) {
+		logger.Warnf("Failed to fetch talk page: %v", err)
+		metrics.EditStatus.With(prometheus.Labels{"state": "user_warning", "status": "failure"}).Inc()
+		return false
+	}
+
+	currentWarning := wikipedia.ParseWarningLevel(talkPageText, time.Now().UTC(), configuration.Bot.WarningWindow)
+	warningLevel := currentWarning.Level
+	logger.Infof("Found current warning level for user: %+v", currentWarning)
+	if warningLevel >= 4 {
+		evidence := fmt.Sprintf("[[%s]] was [%s changed] ANN scored at %f",
+			strings.ReplaceAll(change.TitleWithNamespace(), "File:", ":File:"),
+			change.GetDiffUrl(),
+			change.VandalismScore,
 		)
-		comment := fmt.Sprintf("Automatically reporting [[Special:Contributions/%s]]. (bot)", change.User.Username)
 
+		// Decided against the revision being edited, so concurrent processors cannot both add a report
+		reported := false
 		logger.Infof("Reporting user to AIV")
-		if !api.AppendToPage(logger, ctx, "Wikipedia:Administrator_intervention_against_vandalism/TB2", notice, comment) {
-			metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "failed"}).Inc()
+		if err := api.UpdatePage(logger, ctx, aiv.Page, func(current string) (string, string, error) {
+			if aiv.FindReport(current, change.User.Username) != nil {
+				reported = true
+				content, err := aiv.AddEvidence(current, change.User.Username, change.GetDiffUrl(), evidence)
+				return content, fmt.Sprintf("Adding evidence to report of [[Special:Contributions/%s]]. (bot)", change.User.Username), err
+			}
+			reported = false
+			content := fmt.Sprintf("%s\n\n%s", current, aiv.NewReport(change.User.Username, report))
+			return content, fmt.Sprintf("Automatically reporting [[Special:Contributions/%s]]. (bot)", change.User.Username), nil
+		}); err != nil {
+			logger.Warnf("Failed to report user: %v", err)
+			if errors.Is(err, aiv.ErrEvidencePresent) || errors.Is(err, aiv.ErrNotReported) {
+				metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "skipped"}).Inc()
+			} else {
+				metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": apiErrorStatus(err, "failed")}).Inc()
+			}
 			return false
 		}
-		metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "success"}).Inc()
+		if reported {
+			logger.Infof("User was already reported to AIV, added evidence")
+			metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "evidence_added"}).Inc()
+		} else {
+			metrics.EditStatus.With(prometheus.Labels{"state": "avi_report", "status": "success"}).Inc()
+		}
 		return true
 	} else {
-		warning := fmt.Sprintf("{{subst:User:%s/Warnings/Warning", configuration.Wikipedia.Username)
-		warning += fmt.Sprintf("|1=%d", warningLevel)
-		warning += fmt.Sprintf("|2=%s", strings.ReplaceAll("File:", ":File:", change.Common.Title))
-		warning += fmt.Sprintf("|3=%s", report)
-		warning += fmt.Sprintf(" <!{{subst:ns:0}}-- MySQL ID: %d --{{subst:ns:0}}>", mysqlVandalismId)
-		warning += fmt.Sprintf("|4=%d}} ~~~~", mysqlVandalismId)
+		if template := exclusion.Check(talkPageText, []string{configuration.Wikipedia.Username}, exclusion.MessageVandalism); template != nil {
+			logger.Infof("Not warning user due to %s", template.Raw)
+			metrics.EditStatus.With(prometheus.Labels{"state": "user_warning", "status": "excluded"}).Inc()
+			return false
+		}
+
+		selection := selectWarningTemplate(logger, change, configuration, warning.Values{
+			Bot:         configuration.Wikipedia.Username,
+			Level:       warningLevel,
+			Page:        strings.ReplaceAll(change.Common.Title, "File:", ":File:"),
+			Report:      report,
+			VandalismId: mysqlVandalismId,
+		})
+		if err := db.ClueBot.SaveVandalismWarningTemplate(logger, ctx, mysqlVandalismId, selection.Template); err != nil {
+			logger.Warnf("Failed to save warning template: %v", err)
+		}
+
 		comment := fmt.Sprintf("Warning [[Special:Contributions/%s|%s]] - #%d", change.User.Username, change.User.Username, warningLevel)
 
 		logger.Infof("Warning user")
-		if !api.AppendToPage(logger, ctx, fmt.Sprintf("User Talk:%s", change.User.Username), warning, comment) {
-			metrics.EditStatus.With(prometheus.Labels{"state": "user_warning", "status": "failure"}).Inc()
+		if err := api.AppendToMonthlySection(logger, ctx, fmt.Sprintf("User talk:%s", change.User.Username), selection.Wikitext, comment, time.Now()); err != nil {
+			logger.Warnf("Failed to warn user: %v", err)
+			metrics.EditStatus.With(prometheus.Labels{"state": "user_warning", "status": apiErrorStatus(err, "failure")}).Inc()
 			return false
 		}
 		metrics.EditStatus.With(prometheus.Labels{"state": "user_warning", "status": "success"}).Inc()
@@ -138,124 +296,30 @@ func doWarn(l *logrus.Entry, parentCtx context.Context, api *wikipedia.Wikipedia
 	}
 }
 
+// The revert policy is shared by every processor, it is rebuilt when the on-wiki policy changes
+var revertPolicies = policy.Cache{}
+
 func shouldRevert(l *logrus.Entry, parentCtx context.Context, configuration *config.Configuration, db *database.DatabaseConnection, change *model.ProcessEvent) bool {
 	logger := l.WithField("function", "processor.shouldRevert")
 	ctx, span := metrics.OtelTracer.Start(parentCtx, "revert.shouldRevert")
 	defer span.End()
 
-	change.RevertReason = "Default Revert"
-
-	if !configuration.Bot.Run {
-		logger.Infof("Not reverting due to running disabled locally")
-		change.RevertReason = "Run Disabled"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": "local_config"}).Inc()
-		return false
-	}
-
-	if !configuration.Dynamic.Run {
-		logger.Infof("Not reverting due to running disabled remotely")
-		change.RevertReason = "Run Disabled"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": "remote_config"}).Inc()
-		return false
-	}
-
-	if change.User.Username == configuration.Wikipedia.Username {
-		logger.Infof("Not reverting due to self change")
-		change.RevertReason = "User is myself"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": "self_edit"}).Inc()
-		return false
-	}
-
-	if configuration.Bot.Angry {
-		logger.Infof("Reverting due to angry mode")
-		change.RevertReason = "Angry-reverting in angry mode"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "success", "meta": "angry"}).Inc()
-		return true
-	}
-
-	if strings.Contains(change.Current.Text, "{{nobots}}") {
-		logger.Infof("Not reverting due to nobots")
-		change.RevertReason = "Exclusion compliance"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": "nobots"}).Inc()
-		return false
-	}
-
-	for _, name := range []string{
-		configuration.Wikipedia.Username,
-		strings.ReplaceAll(configuration.Wikipedia.Username, " ", "_"),
-	} {
-		noBotsDenyRegex := regexp.MustCompile(`{{bots\s*\|\s*deny\s*=[^}]*(` + regexp.QuoteMeta(name) + `|\*)[^}]*}}`)
-		if noBotsDenyRegex.MatchString(change.Current.Text) {
-			logger.Infof("Not reverting due to bots deny")
-			change.RevertReason = "Exclusion compliance"
-			metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "skipped", "meta": "exclusion_deny"}).Inc()
-			return false
-		}
-
-		noBotsAllows := regexp.MustCompile(`{{bots\s*\|\s*allow\s*=([^}]*)}}`).FindAllStringSubmatch(change.Current.Text, 1)
-		if len(noBotsAllows) == 1 {
-			if !strings.Contains(noBotsAllows[0][1], name) {
-				logger.Infof("Not reverting due to no bots allow")
-				change.RevertReason = "Exclusion compliance"
-				metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "skipped", "meta": "exclusion_allow"}).Inc()
-				return false
-			}
-		}
-	}
-
-	if change.User.Username == change.Common.Creator {
-		logger.Infof("Not reverting due to page creator being the user")
-		change.RevertReason = "User is creator"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "skipped", "meta": "common_creator"}).Inc()
-		return false
-	}
-
-	if change.User.EditCount > 50 {
-		userWarnRatio := float64(change.User.Warns / change.User.EditCount)
-		if userWarnRatio < 0.1 {
-			logger.Infof("Not reverting due to user edit count")
-			change.RevertReason = "User has edit count"
-			metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "skipped", "meta": "high_edit_count"}).Inc()
-			return false
-		}
-		logger.Infof("Found user edit count, but high warns (%f)", userWarnRatio)
-		change.RevertReason = "User has edit count, but warns > 10%"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "success", "meta": "edit_count_warn_perc"}).Inc()
-		return true
-	}
-
-	if change.Common.Title == configuration.Dynamic.TFA {
-		logger.Infof("Reverting due to page being TFA")
-		change.RevertReason = "Angry-reverting on TFA"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "success", "meta": "angry_tfa"}).Inc()
-		return true
-	}
-
-	for _, page := range configuration.Dynamic.AngryOptinPages {
-		if change.Common.Title == page {
-			logger.Infof("Reverting due to angry optin")
-			change.RevertReason = "Angry-reverting on angry-optin"
-			metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "success", "meta": "angry_opt_in"}).Inc()
-			return true
-		}
-	}
-
-	// If we reverted this user/page before in the last 24 hours, don't
-	lastRevertTime, err := db.ClueBot.GetLastRevertTime(logger, ctx, change.Common.Title, change.User.Username)
+	revertPolicy, err := revertPolicies.Get(logger, configuration)
 	if err != nil {
-		change.RevertReason = "Database error"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": "database_error"}).Inc()
-		return false
-	}
-
-	if lastRevertTime != 0 && lastRevertTime > time.Now().UTC().Unix()-config.RecentRevertThreshold {
-		change.RevertReason = "Reverted before"
-		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "skipped", "meta": "recent_revert"}).Inc()
+		logger.Errorf("Failed to build revert policy: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		change.RevertReason = "Invalid revert policy"
+		metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "failed", "meta": "invalid_policy"}).Inc()
 		return false
 	}
 
-	metrics.RevertStatus.With(prometheus.Labels{"state": "should_revert", "status": "success", "meta": "fallback"}).Inc()
-	return true
+	return revertPolicy.Evaluate(&policy.Evaluation{
+		Logger:        logger,
+		Ctx:           ctx,
+		Configuration: configuration,
+		Db:            db.ClueBot,
+		Change:        change,
+	})
 }
 
 func processSingleRevertChange(logger *logrus.Entry, parentCtx context.Context, change *model.ProcessEvent, configuration *config.Configuration, db *database.DatabaseConnection, r *relay.Relays, api *wikipedia.WikipediaApi) error {
@@ -266,19 +330,20 @@ func processSingleRevertChange(logger *logrus.Entry, parentCtx context.Context,
 	// This is Vandalism, first generate an id
 	mysqlVandalismId, err := db.ClueBot.GenerateVandalismId(logger,
 		ctx,
-		change.User.Username,
-		change.Common.Title,
-		fmt.Sprintf("ANN scored at %f", change.VandalismScore),
-		change.GetDiffUrl(),
-		change.Previous.Id,
-		change.Current.Id)
+		configuration.Core,
+		change,
+		fmt.Sprintf("ANN scored at %f", change.VandalismScore))
 	if err != nil {
 		return fmt.Errorf("failed to generate vandalism id: %v", err)
 	}
 	logger.Infof("Generated vandalism id %v", mysqlVandalismId)
 
 	// Revert or not
-	if !shouldRevert(logger, ctx, configuration, db, change) {
+	revert := shouldRevert(logger, ctx, configuration, db, change)
+	if err := db.ClueBot.SaveVandalismRevertReason(logger, ctx, mysqlVandalismId, change.RevertReason); err != nil {
+		logger.Warnf("Failed to save revert reason: %v", err)
+	}
+	if !revert {
 		metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "skipped"}).Inc()
 		logger.Infof("Should not revert: %s", change.RevertReason)
 		return nil
@@ -290,10 +355,11 @@ func processSingleRevertChange(logger *logrus.Entry, parentCtx context.Context,
 		logger.Warnf("Failed to save revert time: %v", err)
 	}
 
-	if revertChange(logger, ctx, api, change, configuration, mysqlVandalismId) {
+	result := revertChange(logger, ctx, api, change, configuration, mysqlVandalismId)
+	if result.Reverted {
 		metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "success"}).Inc()
 		logger.Infof("Reverted successfully")
-		doWarn(logger, ctx, api, r, change, configuration, mysqlVandalismId)
+		doWarn(logger, ctx, api, db, r, change, configuration, mysqlVandalismId)
 		if err := db.ClueBot.MarkVandalismRevertedSuccessfully(logger, ctx, mysqlVandalismId); err != nil {
 			logger.Warnf("Failed to mark vandalism as reverted in database: %v", err)
 		}
@@ -301,28 +367,54 @@ func processSingleRevertChange(logger *logrus.Entry, parentCtx context.Context,
 		r.SendRevert(fmt.Sprintf("%s (Reverted) (%s) (%d s)", change.FormatIrcRevert(), change.RevertReason, time.Now().Unix()-change.ChangeTime.Unix()))
 		return nil
 	}
-	logger.Infof("Failed to revert")
-	revision := api.GetPage(logger, ctx, helpers.PageTitle(change.Common.Namespace, change.Common.Title))
-	if revision != nil {
-		if change.User.Username == revision.User {
-			metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "self_beaten"}).Inc()
+	logger.Infof("Failed to revert (%s)", result.State)
+
+	switch result.State {
+	case topRevisionSelfReverted, topRevisionBeaten:
+		metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": result.State}).Inc()
+		if result.State == topRevisionSelfReverted {
+			change.RevertReason = "Self reverted"
 		} else {
-			metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "beaten"}).Inc()
-			change.RevertReason = fmt.Sprintf("Beaten by %s", revision.User)
-			if err := db.ClueBot.MarkVandalismRevertBeaten(logger, ctx, mysqlVandalismId, change.Common.Title, change.GetDiffUrl(), revision.User); err != nil {
-				logger.Warnf("Failed to mark revert as beaten in database: %v", err)
-			}
+			change.RevertReason = fmt.Sprintf("Beaten by %s", result.Top.User)
+		}
+		if err := db.ClueBot.MarkVandalismRevertBeaten(logger, ctx, mysqlVandalismId, change.Common.Title, change.GetDiffUrl(), result.Top.User, result.State); err != nil {
+			logger.Warnf("Failed to mark revert as beaten in database: %v", err)
+		}
 
+		if result.State != topRevisionSelfReverted {
 			r.SendRevert(fmt.Sprintf("%s (Not Reverted) (%s) (%d s)", change.FormatIrcRevert(), change.RevertReason, time.Now().Unix()-change.ChangeTime.Unix()))
 		}
 		return nil
+	case revertTitleBlacklisted:
+		metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": result.State}).Inc()
+		change.RevertReason = "Title blacklisted"
+		r.SendRevert(fmt.Sprintf("%s (Not Reverted) (%s) (%d s)", change.FormatIrcRevert(), change.RevertReason, time.Now().Unix()-change.ChangeTime.Unix()))
+		return nil
+	case topRevisionNewerEdit:
+		// Someone else edited without restoring the previous version, so nobody reverted it; leave it to a human
+		metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": result.State}).Inc()
+		change.RevertReason = fmt.Sprintf("Newer edit by %s", result.Top.User)
+		r.SendRevert(fmt.Sprintf("%s (Not Reverted) (%s) (%d s)", change.FormatIrcRevert(), change.RevertReason, time.Now().Unix()-change.ChangeTime.Unix()))
+		return nil
 	}
 
 	metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "failed"}).Inc()
 	return nil
 }
 
-func ProcessRevertChangeEvents(wg *sync.WaitGroup, configuration *config.Configuration, db *database.DatabaseConnection, r *relay.Relays, api *wikipedia.WikipediaApi, inChangeFeed chan *model.ProcessEvent) {
+// dryRunRevertChange evaluates the revert rules like explain does, nothing is written to the database, wiki or relays
+func dryRunRevertChange(logger *logrus.Entry, ctx context.Context, change *model.ProcessEvent, configuration *config.Configuration, db *database.DatabaseConnection) {
+	if shouldRevert(logger, ctx, configuration, db, change) {
+		logger.Infof("Would revert: %s", change.RevertReason)
+		metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "dry_run"}).Inc()
+		return
+	}
+	logger.Infof("Would not revert: %s", change.RevertReason)
+	metrics.EditStatus.With(prometheus.Labels{"state": "revert", "status": "skipped"}).Inc()
+}
+
+// ProcessRevertChangeEvents acts on vandalism, with dryRun set only the revert decision is logged
+func ProcessRevertChangeEvents(wg *sync.WaitGroup, configuration *config.Configuration, db *database.DatabaseConnection, r *relay.Relays, api *wikipedia.WikipediaApi, dryRun bool, inChangeFeed chan *model.ProcessEvent) {
 
 	defer wg.Done()
 	for change := range inChangeFeed {
@@ -334,10 +426,13 @@ func ProcessRevertChangeEvents(wg *sync.WaitGroup, configuration *config.Configu
 			ctx, span := metrics.OtelTracer.Start(change.TraceContext, "ProcessRevertChangeEvents")
 			defer span.End()
 
-			if err := processSingleRevertChange(logger, ctx, change, configuration, db, r, api); err != nil {
+			if dryRun {
+				dryRunRevertChange(logger, ctx, change, configuration, db)
+			} else if err := processSingleRevertChange(logger, ctx, change, configuration, db, r, api); err != nil {
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 			}
+			change.Complete(model.OutcomeScored)
 		}(change)
 		metrics.ProcessorsRevertInUse.Dec()
 	}
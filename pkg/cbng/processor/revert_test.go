@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"github.com/cluebotng/botng/pkg/cbng/wikipedia"
+	"testing"
+)
+
+func TestClassifyTopRevision(t *testing.T) {
+	change := &model.ProcessEvent{
+		Current: model.ProcessEventRevision{Id: 200},
+		User:    model.ProcessEventUser{Username: "Vandal"},
+	}
+	revertRevision := &wikipedia.Revision{Id: 100, User: "Editor", Sha1: "good"}
+
+	tests := []struct {
+		name  string
+		top   *wikipedia.Revision
+		state string
+	}{
+		{"current", &wikipedia.Revision{Id: 200, User: "Vandal", Sha1: "bad"}, topRevisionCurrent},
+		{"self reverted", &wikipedia.Revision{Id: 201, User: "Vandal", Sha1: "good"}, topRevisionSelfReverted},
+		{"vandal newer edit", &wikipedia.Revision{Id: 201, User: "Vandal", Sha1: "worse"}, topRevisionVandalNewerEdit},
+		{"beaten", &wikipedia.Revision{Id: 201, User: "Patroller", Sha1: "good"}, topRevisionBeaten},
+		{"newer edit", &wikipedia.Revision{Id: 201, User: "Patroller", Sha1: "other"}, topRevisionNewerEdit},
+		{"newer edit without sha1", &wikipedia.Revision{Id: 201, User: "Patroller"}, topRevisionNewerEdit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if state := classifyTopRevision(change, revertRevision, tt.top); state != tt.state {
+				t.Errorf("expected %s, got %s", tt.state, state)
+			}
+		})
+	}
+}
+
+func TestClassifyTopRevisionHiddenSha1(t *testing.T) {
+	change := &model.ProcessEvent{
+		Current: model.ProcessEventRevision{Id: 200},
+		User:    model.ProcessEventUser{Username: "Vandal"},
+	}
+
+	// Without a sha1 on either side the content cannot be compared, so it is never counted as restored
+	if state := classifyTopRevision(change, &wikipedia.Revision{Id: 100, User: "Editor"}, &wikipedia.Revision{Id: 201, User: "Patroller"}); state != topRevisionNewerEdit {
+		t.Errorf("expected %s, got %s", topRevisionNewerEdit, state)
+	}
+}
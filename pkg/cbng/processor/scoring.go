@@ -47,12 +47,14 @@ func ProcessScoringChangeEvents(wg *sync.WaitGroup, configuration *config.Config
 				logger.Error(err.Error())
 				span.SetStatus(codes.Error, err.Error())
 				r.SendDebug(fmt.Sprintf("%v # Failed to score change", change.FormatIrcChange()))
+				change.Complete(model.OutcomeFailed)
 				return
 			}
 
 			if !isVandalism {
 				logger.Infof("Is not vandalism (scored at %f)", change.VandalismScore)
 				metrics.EditStatus.With(prometheus.Labels{"state": "score_edit", "status": "classified_as_not_vandalism"}).Inc()
+				change.Complete(model.OutcomeScored)
 				return
 			}
 			logger.Infof("Is vandalism (scored at %f)", change.VandalismScore)
@@ -60,6 +62,7 @@ func ProcessScoringChangeEvents(wg *sync.WaitGroup, configuration *config.Config
 			if isWhitelisted(logger, configuration, change.User.Username) {
 				logger.Infof("User is whitelisted, not reverting")
 				metrics.EditStatus.With(prometheus.Labels{"state": "score_edit", "status": "skipped_due_to_whitelist"}).Inc()
+				change.Complete(model.OutcomeScored)
 				return
 			}
 
@@ -0,0 +1,185 @@
+package warning
+
+import (
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/helpers"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type condition func(change *model.ProcessEvent) bool
+
+type conditionBuilder func(params map[string]string) (condition, error)
+
+var conditions = map[string]conditionBuilder{
+	"always": func(params map[string]string) (condition, error) {
+		return func(*model.ProcessEvent) bool { return true }, nil
+	},
+	"blp":       func(params map[string]string) (condition, error) { return isBLP, nil },
+	"test_edit": func(params map[string]string) (condition, error) { return isTestEdit, nil },
+	"blanking":  blankingCondition,
+	"namespace": namespaceCondition,
+}
+
+var livingPeopleRegex = regexp.MustCompile(`(?i)\[\[\s*category\s*:\s*living[ _]people\s*(\|[^\]]*)?\]\]`)
+
+// Sample text inserted by the edit toolbar, which is almost always a test edit
+var toolbarSamples = []string{
+	"'''Bold text'''",
+	"''Italic text''",
+	"[[Link title]]",
+	"[http://www.example.com link title]",
+	"== Headline text ==",
+	"[[File:Example.jpg]]",
+	"[[Media:Example.ogg]]",
+	"<math>Insert formula here</math>",
+	"<nowiki>Insert non-formatted text here</nowiki>",
+	"#REDIRECT [[Target page name]]",
+	"<gallery>\nExample.jpg",
+}
+
+// Values are substituted for the {placeholders} in the template name and arguments
+type Values struct {
+	Bot         string
+	Level       int
+	Page        string
+	Report      string
+	VandalismId int64
+}
+
+func (v Values) replacer() *strings.Replacer {
+	return strings.NewReplacer(
+		"{bot}", v.Bot,
+		"{level}", strconv.Itoa(v.Level),
+		"{next_level}", strconv.Itoa(v.Level+1),
+		"{page}", v.Page,
+		"{report}", v.Report,
+		"{id}", strconv.FormatInt(v.VandalismId, 10),
+	)
+}
+
+type Selection struct {
+	Name     string
+	Template string
+	Wikitext string
+}
+
+// Expand fills in the template and arguments, returning the signed wikitext to substitute onto the talk page
+func Expand(name, template string, arguments []string, values Values) *Selection {
+	replacer := values.replacer()
+	selection := Selection{Name: name, Template: replacer.Replace(template)}
+
+	wikitext := fmt.Sprintf("{{subst:%s", selection.Template)
+	for _, argument := range arguments {
+		wikitext += "|" + replacer.Replace(argument)
+	}
+	selection.Wikitext = wikitext + "}} ~~~~"
+	return &selection
+}
+
+type rule struct {
+	name      string
+	template  string
+	arguments []string
+	condition condition
+}
+
+type Selector struct {
+	rules []rule
+}
+
+func isBLP(change *model.ProcessEvent) bool {
+	return livingPeopleRegex.MatchString(change.Previous.Text) || livingPeopleRegex.MatchString(change.Current.Text)
+}
+
+func isTestEdit(change *model.ProcessEvent) bool {
+	for _, sample := range toolbarSamples {
+		if strings.Contains(change.Current.Text, sample) && !strings.Contains(change.Previous.Text, sample) {
+			return true
+		}
+	}
+	return false
+}
+
+func blankingCondition(params map[string]string) (condition, error) {
+	bytes, err := strconv.ParseInt(params["bytes"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytes '%s': %v", params["bytes"], err)
+	}
+	return func(change *model.ProcessEvent) bool {
+		return change.Length <= -bytes
+	}, nil
+}
+
+func namespaceCondition(params map[string]string) (condition, error) {
+	namespaceIds, err := helpers.ParseNamespaceIds(params["ids"])
+	if err != nil {
+		return nil, err
+	}
+	return func(change *model.ProcessEvent) bool {
+		return namespaceIds[change.Common.NamespaceId]
+	}, nil
+}
+
+func NewSelector(templates []config.WarningTemplateConfiguration) (*Selector, error) {
+	selector := Selector{}
+	for i, templateConfiguration := range templates {
+		if templateConfiguration.Name == "" {
+			return nil, fmt.Errorf("warning template %d: missing name", i)
+		}
+		if templateConfiguration.Template == "" {
+			return nil, fmt.Errorf("warning template %s: missing template", templateConfiguration.Name)
+		}
+
+		builder, ok := conditions[templateConfiguration.Condition]
+		if !ok {
+			return nil, fmt.Errorf("warning template %s: unknown condition '%s'", templateConfiguration.Name, templateConfiguration.Condition)
+		}
+		condition, err := builder(templateConfiguration.Params)
+		if err != nil {
+			return nil, fmt.Errorf("warning template %s: %v", templateConfiguration.Name, err)
+		}
+
+		// Entries configured before arguments were configurable all used the bot's own template
+		arguments := templateConfiguration.Arguments
+		if len(arguments) == 0 {
+			arguments = config.ClueBotWarningArguments
+		}
+
+		selector.rules = append(selector.rules, rule{
+			name:      templateConfiguration.Name,
+			template:  templateConfiguration.Template,
+			arguments: arguments,
+			condition: condition,
+		})
+	}
+	return &selector, nil
+}
+
+// Cache builds the selector once, the templates are only read from the configuration at startup
+type Cache struct {
+	once     sync.Once
+	selector *Selector
+	err      error
+}
+
+func (c *Cache) Get(templates []config.WarningTemplateConfiguration) (*Selector, error) {
+	c.once.Do(func() {
+		c.selector, c.err = NewSelector(templates)
+	})
+	return c.selector, c.err
+}
+
+// Select returns the first matching template, expanded with the values
+func (s *Selector) Select(change *model.ProcessEvent, values Values) *Selection {
+	for _, rule := range s.rules {
+		if rule.condition(change) {
+			return Expand(rule.name, rule.template, rule.arguments, values)
+		}
+	}
+	return nil
+}
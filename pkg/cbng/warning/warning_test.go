@@ -0,0 +1,161 @@
+package warning
+
+import (
+	"github.com/cluebotng/botng/pkg/cbng/config"
+	"github.com/cluebotng/botng/pkg/cbng/model"
+	"testing"
+)
+
+var testValues = Values{
+	Bot:         "ClueBot NG",
+	Level:       1,
+	Page:        "Example",
+	Report:      "[[Example]] was changed",
+	VandalismId: 1234,
+}
+
+func TestExpandClueBotTemplate(t *testing.T) {
+	selection := Expand("default", "User:{bot}/Warnings/Warning", config.ClueBotWarningArguments, testValues)
+
+	if selection.Template != "User:ClueBot NG/Warnings/Warning" {
+		t.Errorf("unexpected template %q", selection.Template)
+	}
+	expected := "{{subst:User:ClueBot NG/Warnings/Warning|1=1|2=Example|3=[[Example]] was changed" +
+		" <!{{subst:ns:0}}-- MySQL ID: 1234 --{{subst:ns:0}}>|4=1234}} ~~~~"
+	if selection.Wikitext != expected {
+		t.Errorf("expected %q, got %q", expected, selection.Wikitext)
+	}
+}
+
+func TestExpandUserWarningTemplate(t *testing.T) {
+	selection := Expand("blanking", "uw-delete{next_level}", config.UserWarningArguments, testValues)
+
+	if selection.Template != "uw-delete2" {
+		t.Errorf("expected the next level template, got %q", selection.Template)
+	}
+	expected := "{{subst:uw-delete2|1=Example|2=If this is a mistake, [[User:ClueBot NG/FalsePositives|please report it]]." +
+		" <!{{subst:ns:0}}-- MySQL ID: 1234 --{{subst:ns:0}}>}} ~~~~"
+	if selection.Wikitext != expected {
+		t.Errorf("expected %q, got %q", expected, selection.Wikitext)
+	}
+}
+
+func TestExpandDoesNotReplaceSubstitutedValues(t *testing.T) {
+	values := testValues
+	values.Page = "{bot} {id}"
+	selection := Expand("default", "uw-vandalism{next_level}", []string{"1={page}"}, values)
+
+	if selection.Wikitext != "{{subst:uw-vandalism2|1={bot} {id}}} ~~~~" {
+		t.Errorf("unexpected wikitext %q", selection.Wikitext)
+	}
+}
+
+func TestSelectDefaultTemplates(t *testing.T) {
+	selector, err := NewSelector(config.DefaultWarningTemplates())
+	if err != nil {
+		t.Fatalf("default templates are invalid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change *model.ProcessEvent
+	}{
+		{"blanking", &model.ProcessEvent{Length: -5000}},
+		{"blp", &model.ProcessEvent{Current: model.ProcessEventRevision{Text: "[[Category:Living people]]"}}},
+		{"other", &model.ProcessEvent{Length: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := selector.Select(tt.change, testValues)
+			if selection == nil {
+				t.Fatalf("expected a selection")
+			}
+			if selection.Name != "default" || selection.Template != "User:ClueBot NG/Warnings/Warning" {
+				t.Errorf("expected the bot's own template, got %s (%s)", selection.Name, selection.Template)
+			}
+		})
+	}
+}
+
+func TestSelectConditions(t *testing.T) {
+	selector, err := NewSelector([]config.WarningTemplateConfiguration{
+		{Name: "blanking", Condition: "blanking", Params: map[string]string{"bytes": "1000"}, Template: "uw-delete{next_level}", Arguments: config.UserWarningArguments},
+		{Name: "test_edit", Condition: "test_edit", Template: "uw-test{next_level}", Arguments: config.UserWarningArguments},
+		{Name: "blp", Condition: "blp", Template: "uw-biog{next_level}", Arguments: config.UserWarningArguments},
+		{Name: "talk", Condition: "namespace", Params: map[string]string{"ids": "1, 3"}, Template: "uw-tpv{next_level}", Arguments: config.UserWarningArguments},
+		{Name: "default", Condition: "always", Template: "User:{bot}/Warnings/Warning"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build selector: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		change   *model.ProcessEvent
+		selected string
+	}{
+		{"blanking", &model.ProcessEvent{Length: -5000}, "blanking"},
+		{"small removal", &model.ProcessEvent{Length: -10}, "default"},
+		{"blanking a blp", &model.ProcessEvent{Length: -5000, Current: model.ProcessEventRevision{Text: "[[Category:Living people]]"}}, "blanking"},
+		{"blp in previous revision", &model.ProcessEvent{Previous: model.ProcessEventRevision{Text: "[[Category: living_people|Foo]]"}}, "blp"},
+		{"toolbar sample added", &model.ProcessEvent{
+			Previous: model.ProcessEventRevision{Text: "Article"},
+			Current:  model.ProcessEventRevision{Text: "Article '''Bold text'''"},
+		}, "test_edit"},
+		{"toolbar sample already present", &model.ProcessEvent{
+			Previous: model.ProcessEventRevision{Text: "Article [[Link title]]"},
+			Current:  model.ProcessEventRevision{Text: "Changed [[Link title]]"},
+		}, "default"},
+		{"talk namespace", &model.ProcessEvent{Common: model.ProcessEventCommon{NamespaceId: 1}}, "talk"},
+		{"user talk namespace", &model.ProcessEvent{Common: model.ProcessEventCommon{NamespaceId: 3}}, "talk"},
+		{"main namespace", &model.ProcessEvent{Common: model.ProcessEventCommon{NamespaceId: 0}}, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := selector.Select(tt.change, testValues)
+			if selection == nil {
+				t.Fatalf("expected a selection")
+			}
+			if selection.Name != tt.selected {
+				t.Errorf("expected %s, got %s", tt.selected, selection.Name)
+			}
+		})
+	}
+}
+
+func TestNewSelectorInvalidNamespace(t *testing.T) {
+	_, err := NewSelector([]config.WarningTemplateConfiguration{
+		{Name: "talk", Condition: "namespace", Params: map[string]string{"ids": "1,talk"}, Template: "uw-tpv1"},
+	})
+	if err == nil {
+		t.Errorf("expected an invalid namespace id to be refused")
+	}
+}
+
+func TestCacheBuildsOnce(t *testing.T) {
+	cache := Cache{}
+	first, err := cache.Get(config.DefaultWarningTemplates())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second, _ := cache.Get(nil); second != first {
+		t.Errorf("expected the selector to be reused")
+	}
+}
+
+func TestSelectDefaultsArguments(t *testing.T) {
+	selector, err := NewSelector([]config.WarningTemplateConfiguration{
+		{Name: "default", Condition: "always", Template: "User:{bot}/Warnings/Warning"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build selector: %v", err)
+	}
+
+	selection := selector.Select(&model.ProcessEvent{}, testValues)
+	expected := Expand("default", "User:{bot}/Warnings/Warning", config.ClueBotWarningArguments, testValues)
+	if selection.Wikitext != expected.Wikitext {
+		t.Errorf("expected the bot's own template arguments, got %q", selection.Wikitext)
+	}
+}
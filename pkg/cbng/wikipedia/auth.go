@@ -0,0 +1,145 @@
+package wikipedia
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/url"
+	"strings"
+)
+
+const (
+	AuthPassword = "password"
+	AuthOAuth    = "oauth"
+)
+
+// Rights the bot needs, anything else granted to the consumer is unnecessary
+var requiredRights = []string{"edit", "rollback"}
+
+type Credentials struct {
+	Method      string
+	Username    string
+	Password    string
+	AccessToken string
+}
+
+func (c Credentials) Validate() error {
+	switch c.Method {
+	case AuthPassword:
+		if c.Password == "" {
+			return errors.New("password authentication requires a password")
+		}
+	case AuthOAuth:
+		if c.AccessToken == "" {
+			return errors.New("oauth authentication requires an access token")
+		}
+	default:
+		return fmt.Errorf("unknown authentication method: %s", c.Method)
+	}
+	return nil
+}
+
+func (w *WikipediaApi) login() error {
+	switch w.credentials.Method {
+	case AuthOAuth:
+		return w.verifyOAuth()
+	case AuthPassword:
+		return w.passwordLogin()
+	}
+	return fmt.Errorf("unknown authentication method: %s", w.credentials.Method)
+}
+
+// verifyOAuth checks the bearer token identifies the expected user, there is no session to establish
+func (w *WikipediaApi) verifyOAuth() error {
+	logger := logrus.WithField("function", "wikipedia.WikipediaApi.verifyOAuth")
+
+	data := struct {
+		Query struct {
+			UserInfo struct {
+				Name   string   `json:"name"`
+				Anon   bool     `json:"anon"`
+				Rights []string `json:"rights"`
+			} `json:"userinfo"`
+		} `json:"query"`
+	}{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action": []string{"query"},
+		"meta":   []string{"userinfo"},
+		"uiprop": []string{"rights"},
+	}, &data); err != nil {
+		return fmt.Errorf("failed to verify access token: %w", err)
+	}
+
+	userInfo := data.Query.UserInfo
+	if userInfo.Anon {
+		return fmt.Errorf("access token was not accepted: %w", ErrNotLoggedIn)
+	}
+	if !strings.EqualFold(strings.ReplaceAll(userInfo.Name, " ", "_"), strings.ReplaceAll(w.credentials.Username, " ", "_")) {
+		return fmt.Errorf("access token belongs to %s, expected %s", userInfo.Name, w.credentials.Username)
+	}
+
+	for _, right := range requiredRights {
+		found := false
+		for _, granted := range userInfo.Rights {
+			if granted == right {
+				found = true
+				break
+			}
+		}
+		if !found {
+			logger.Warnf("Access token is missing the %s right", right)
+		}
+	}
+	logger.Debugf("Authenticated to Wikipedia as %s (oauth)", userInfo.Name)
+	return nil
+}
+
+func (w *WikipediaApi) attemptLogin(reqData url.Values) (bool, *string) {
+	logger := logrus.WithField("function", "wikipedia.WikipediaApi.attemptLogin")
+
+	logger.Tracef("Attempting login")
+	data := loginResponse{}
+	if err := w.apiCall(logger, "POST", reqData, &data); err != nil {
+		logger.Errorf("Failed to login: %v", err)
+		return false, nil
+	}
+
+	if data.Login.Result == "Success" {
+		logger.Tracef("Got Success")
+		return true, nil
+	}
+
+	if data.Login.Result == "NeedToken" {
+		logger.Tracef("Got NeedToken")
+		return false, &data.Login.Token
+	}
+	return false, nil
+}
+
+func (w *WikipediaApi) passwordLogin() error {
+	logger := logrus.WithField("function", "wikipedia.WikipediaApi.passwordLogin")
+	success, loginToken := w.attemptLogin(url.Values{
+		"action":     []string{"login"},
+		"lgname":     []string{w.credentials.Username},
+		"lgpassword": []string{w.credentials.Password},
+	})
+	if success {
+		logger.Debug("Logged into Wikipedia (no token)")
+		return nil
+	}
+
+	if loginToken != nil {
+		success, _ = w.attemptLogin(url.Values{
+			"action":     []string{"login"},
+			"lgname":     []string{w.credentials.Username},
+			"lgpassword": []string{w.credentials.Password},
+			"lgtoken":    []string{*loginToken},
+		})
+		if success {
+			logger.Debug("Logged into Wikipedia (token)")
+			return nil
+		}
+	}
+
+	return errors.New("failed to login to Wikipedia")
+}
@@ -0,0 +1,120 @@
+package wikipedia
+
+import (
+	"context"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// The API limit for revids when requesting content without apihighlimits
+const maxBatchRevisionIds = 50
+
+type revisionBatchResult struct {
+	revisions map[int64]Revision
+	err       error
+	batch     trace.SpanContext
+}
+
+type revisionBatchRequest struct {
+	ctx    context.Context
+	revIds []int64
+	result chan revisionBatchResult
+}
+
+// RevisionBatcher groups concurrent revision lookups into a single request, waiting at most maxWait for a batch to fill
+type RevisionBatcher struct {
+	api      *WikipediaApi
+	maxWait  time.Duration
+	requests chan *revisionBatchRequest
+}
+
+func NewRevisionBatcher(api *WikipediaApi, maxWait time.Duration) *RevisionBatcher {
+	b := RevisionBatcher{
+		api:      api,
+		maxWait:  maxWait,
+		requests: make(chan *revisionBatchRequest, maxBatchRevisionIds),
+	}
+	go b.run()
+	return &b
+}
+
+// GetRevisions returns the revisions that could be loaded, missing ids should be fetched individually
+func (b *RevisionBatcher) GetRevisions(l *logrus.Entry, ctx context.Context, revIds ...int64) (map[int64]Revision, error) {
+	request := revisionBatchRequest{
+		ctx:    ctx,
+		revIds: revIds,
+		result: make(chan revisionBatchResult, 1),
+	}
+	b.requests <- &request
+	result := <-request.result
+	trace.SpanFromContext(ctx).AddLink(trace.Link{SpanContext: result.batch})
+	l.WithField("function", "wikipedia.RevisionBatcher.GetRevisions").Tracef("Got %d of %d revisions from batch", len(result.revisions), len(revIds))
+	return result.revisions, result.err
+}
+
+func (b *RevisionBatcher) run() {
+	pending := []*revisionBatchRequest{}
+	pendingIds := 0
+	var timer <-chan time.Time
+
+	flush := func() {
+		if len(pending) > 0 {
+			go b.fetch(pending)
+		}
+		pending = []*revisionBatchRequest{}
+		pendingIds = 0
+		timer = nil
+	}
+
+	for {
+		select {
+		case request := <-b.requests:
+			if pendingIds+len(request.revIds) > maxBatchRevisionIds {
+				flush()
+			}
+			pending = append(pending, request)
+			pendingIds += len(request.revIds)
+			if pendingIds >= maxBatchRevisionIds {
+				flush()
+			} else if timer == nil {
+				timer = time.After(b.maxWait)
+			}
+		case <-timer:
+			flush()
+		}
+	}
+}
+
+func (b *RevisionBatcher) fetch(requests []*revisionBatchRequest) {
+	// The batch is shared by every request, so it runs under its own trace, linked to and from each request's span
+	links := []trace.Link{}
+	for _, request := range requests {
+		links = append(links, trace.LinkFromContext(request.ctx))
+	}
+	logger := logrus.WithField("function", "wikipedia.RevisionBatcher.fetch")
+	ctx, span := metrics.OtelTracer.Start(context.Background(), "wikipedia.RevisionBatcher.fetch", trace.WithLinks(links...))
+	defer span.End()
+
+	revIds := []int64{}
+	for _, request := range requests {
+		revIds = append(revIds, request.revIds...)
+	}
+	metrics.WikipediaRevisionBatchSize.Observe(float64(len(revIds)))
+	logger.Debugf("Fetching %d revisions for %d requests", len(revIds), len(requests))
+
+	revisions, err := b.api.GetRevisionsById(logger, ctx, revIds)
+	for _, request := range requests {
+		result := revisionBatchResult{err: err, batch: span.SpanContext()}
+		if err == nil {
+			result.revisions = map[int64]Revision{}
+			for _, revId := range request.revIds {
+				if revision, ok := revisions[revId]; ok {
+					result.revisions[revId] = revision
+				}
+			}
+		}
+		request.result <- result
+	}
+}
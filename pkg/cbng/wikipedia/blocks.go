@@ -0,0 +1,96 @@
+package wikipedia
+
+import (
+	"context"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"net"
+	"net/url"
+)
+
+type Block struct {
+	Id        int64
+	User      string
+	By        string
+	Timestamp int64
+	Expiry    string
+	Reason    string
+}
+
+type blockEntry struct {
+	Id        int64  `json:"id"`
+	User      string `json:"user"`
+	By        string `json:"by"`
+	Timestamp string `json:"timestamp"`
+	Expiry    string `json:"expiry"`
+	Reason    string `json:"reason"`
+	Partial   bool   `json:"partial"`
+}
+
+// sitewideBlock returns the first block stopping the user editing everywhere, partial blocks leave the user able to
+// vandalise other pages
+func sitewideBlock(entries []blockEntry) *Block {
+	for _, entry := range entries {
+		if entry.Partial {
+			continue
+		}
+
+		block := Block{
+			Id:     entry.Id,
+			User:   entry.User,
+			By:     entry.By,
+			Expiry: entry.Expiry,
+			Reason: entry.Reason,
+		}
+		if val, err := parseTimestamp(entry.Timestamp); err == nil {
+			block.Timestamp = val
+		}
+		return &block
+	}
+	return nil
+}
+
+// GetUserBlock returns the active sitewide block for a user, or nil if they are not blocked from editing everywhere.
+// IPs also match range blocks
+func (w *WikipediaApi) GetUserBlock(l *logrus.Entry, ctx context.Context, user string) (*Block, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.GetUserBlock",
+		"args": map[string]interface{}{
+			"user": user,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetUserBlock")
+	defer span.End()
+
+	params := url.Values{
+		"action":  []string{"query"},
+		"list":    []string{"blocks"},
+		"bkprop":  []string{"id|user|by|timestamp|expiry|reason|range|restrictions"},
+		"bklimit": []string{"50"},
+	}
+	if net.ParseIP(user) != nil {
+		params.Set("bkip", user)
+	} else {
+		params.Set("bkusers", user)
+	}
+
+	data := struct {
+		Query struct {
+			Blocks []blockEntry `json:"blocks"`
+		} `json:"query"`
+	}{}
+	logger.Tracef("Starting request")
+	if err := w.apiCall(logger, "GET", params, &data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.Errorf("Failed to query blocks (%s): %v", user, err)
+		return nil, err
+	}
+	logger.Tracef("Got response")
+
+	block := sitewideBlock(data.Query.Blocks)
+	if block == nil && len(data.Query.Blocks) > 0 {
+		logger.Debugf("Ignoring %d partial blocks", len(data.Query.Blocks))
+	}
+	return block, nil
+}
@@ -0,0 +1,90 @@
+package wikipedia
+
+import (
+	"context"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diff holds the changed lines between two revisions, Added/Removed are only the inline changes where known
+type Diff struct {
+	AddedLines   []string
+	RemovedLines []string
+	Added        []string
+	Removed      []string
+}
+
+var diffCellRegex = regexp.MustCompile(`(?s)<td class="(diff-addedline|diff-deletedline)[^"]*"[^>]*>(.*?)</td>`)
+var diffInlineRegex = regexp.MustCompile(`(?s)<(ins|del) class="diffchange[^"]*">(.*?)</(?:ins|del)>`)
+var htmlTagRegex = regexp.MustCompile(`<[^>]+>`)
+
+func stripHtml(value string) string {
+	return html.UnescapeString(htmlTagRegex.ReplaceAllString(value, ""))
+}
+
+// ParseDiff extracts the changed lines from the HTML table returned by action=compare
+func ParseDiff(body string) Diff {
+	diff := Diff{}
+	for _, cell := range diffCellRegex.FindAllStringSubmatch(body, -1) {
+		line := stripHtml(cell[2])
+
+		changed := []string{}
+		for _, inline := range diffInlineRegex.FindAllStringSubmatch(cell[2], -1) {
+			changed = append(changed, stripHtml(inline[2]))
+		}
+		if len(changed) == 0 {
+			changed = []string{line}
+		}
+
+		if cell[1] == "diff-addedline" {
+			diff.AddedLines = append(diff.AddedLines, line)
+			diff.Added = append(diff.Added, changed...)
+		} else {
+			diff.RemovedLines = append(diff.RemovedLines, line)
+			diff.Removed = append(diff.Removed, changed...)
+		}
+	}
+	return diff
+}
+
+func (w *WikipediaApi) Compare(l *logrus.Entry, ctx context.Context, fromRevId, toRevId int64) (*Diff, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.Compare",
+		"args": map[string]interface{}{
+			"fromRevId": fromRevId,
+			"toRevId":   toRevId,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.Compare")
+	defer span.End()
+
+	logger.Tracef("Starting request")
+	data := struct {
+		Compare struct {
+			Body string `json:"body"`
+		} `json:"compare"`
+	}{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action":  []string{"compare"},
+		"fromrev": []string{strconv.FormatInt(fromRevId, 10)},
+		"torev":   []string{strconv.FormatInt(toRevId, 10)},
+		"prop":    []string{"diff"},
+	}, &data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to compare %d to %d: %w", fromRevId, toRevId, err)
+	}
+	logger.Tracef("Got response")
+
+	if strings.TrimSpace(data.Compare.Body) == "" {
+		return &Diff{}, nil
+	}
+	diff := ParseDiff(data.Compare.Body)
+	return &diff, nil
+}
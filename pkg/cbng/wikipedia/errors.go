@@ -0,0 +1,60 @@
+package wikipedia
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrProtected      = errors.New("protected")
+	ErrBlocked        = errors.New("blocked")
+	ErrEditConflict   = errors.New("edit conflict")
+	ErrBadToken       = errors.New("bad token")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrAlreadyRolled  = errors.New("already rolled back")
+	ErrIncompleteData = errors.New("incomplete data")
+	ErrNotLoggedIn    = errors.New("not logged in")
+	ErrTextHidden     = errors.New("revision text hidden")
+	ErrUserHidden     = errors.New("revision user hidden")
+	ErrSuppressed     = errors.New("revision suppressed")
+
+	// ErrTitleBlacklisted is a kind of protection, so it also matches ErrProtected
+	ErrTitleBlacklisted = fmt.Errorf("title blacklisted: %w", ErrProtected)
+)
+
+var errorCodes = map[string]error{
+	"missingtitle":                 ErrNotFound,
+	"nosuchrevid":                  ErrNotFound,
+	"nosuchpageid":                 ErrNotFound,
+	"protectedpage":                ErrProtected,
+	"protectedtitle":               ErrProtected,
+	"protectednamespace":           ErrProtected,
+	"protectednamespace-interface": ErrProtected,
+	"cascadeprotected":             ErrProtected,
+	"titleblacklist-forbidden":     ErrTitleBlacklisted,
+	"blocked":                      ErrBlocked,
+	"autoblocked":                  ErrBlocked,
+	"editconflict":                 ErrEditConflict,
+	"articleexists":                ErrEditConflict, // createonly, the page was created after it was found missing
+	"badtoken":                     ErrBadToken,
+	"ratelimited":                  ErrRateLimited,
+	"maxlag":                       ErrRateLimited,
+	"alreadyrolled":                ErrAlreadyRolled,
+	"assertuserfailed":             ErrNotLoggedIn,
+	"assertbotfailed":              ErrNotLoggedIn,
+}
+
+// ApiError is an error returned by the API, matching one of the Err* values with errors.Is where known
+type ApiError struct {
+	Code string `json:"code"`
+	Info string `json:"info"`
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Info)
+}
+
+func (e *ApiError) Unwrap() error {
+	return errorCodes[e.Code]
+}
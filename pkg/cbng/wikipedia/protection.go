@@ -0,0 +1,89 @@
+package wikipedia
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/url"
+	"time"
+)
+
+type Protection struct {
+	Type   string
+	Level  string
+	Expiry string
+	Source string
+}
+
+// Protection levels are named after the right needed, except for these legacy names
+var protectionLevelRights = map[string]string{
+	"sysop":         "editprotected",
+	"autoconfirmed": "editsemiprotected",
+}
+
+func (p Protection) active(now time.Time) bool {
+	if p.Expiry == "" || p.Expiry == "infinity" || p.Expiry == "infinite" {
+		return true
+	}
+	expiry, err := time.Parse(time.RFC3339, p.Expiry)
+	if err != nil {
+		return true
+	}
+	return expiry.After(now)
+}
+
+func (w *WikipediaApi) loadUserRights(logger *logrus.Entry) error {
+	data := struct {
+		Query struct {
+			UserInfo struct {
+				Rights []string `json:"rights"`
+			} `json:"userinfo"`
+		} `json:"query"`
+	}{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action": []string{"query"},
+		"meta":   []string{"userinfo"},
+		"uiprop": []string{"rights"},
+	}, &data); err != nil {
+		return fmt.Errorf("failed to query user rights: %w", err)
+	}
+
+	w.rightsLock.Lock()
+	defer w.rightsLock.Unlock()
+	w.rights = data.Query.UserInfo.Rights
+	return nil
+}
+
+func (w *WikipediaApi) hasRight(right string) bool {
+	w.rightsLock.RLock()
+	defer w.rightsLock.RUnlock()
+	for _, granted := range w.rights {
+		if granted == right {
+			return true
+		}
+	}
+	return false
+}
+
+// EditBlockedBy returns the active edit protection the bot cannot edit through, or nil if it can (or its rights are unknown)
+func (w *WikipediaApi) EditBlockedBy(protection []Protection, now time.Time) *Protection {
+	w.rightsLock.RLock()
+	known := w.rights != nil
+	w.rightsLock.RUnlock()
+	if !known {
+		return nil
+	}
+
+	for _, entry := range protection {
+		if entry.Type != "edit" || entry.Level == "" || !entry.active(now) {
+			continue
+		}
+		right, ok := protectionLevelRights[entry.Level]
+		if !ok {
+			right = entry.Level
+		}
+		if !w.hasRight(right) {
+			return &entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package wikipedia
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiUrl = "https://en.wikipedia.org/w/api.php"
+
+// Per https://www.mediawiki.org/wiki/Manual:Maxlag_parameter, bots should use 5 seconds
+const maxLag = 5
+
+const maxRequestAttempts = 5
+const maxBackoff = time.Minute
+
+type throttle struct {
+	reason     string
+	retryAfter time.Duration
+	// MediaWiki refuses maxlag and ratelimited before doing anything, an HTTP error may come after a write was saved
+	refused bool
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// checkThrottle returns why the API asked us to slow down, or nil if the response can be used
+func checkThrottle(response *http.Response, body []byte) *throttle {
+	retryAfter := parseRetryAfter(response.Header.Get("Retry-After"))
+	switch response.StatusCode {
+	case http.StatusTooManyRequests:
+		return &throttle{reason: "http_429", retryAfter: retryAfter}
+	case http.StatusServiceUnavailable:
+		return &throttle{reason: "http_503", retryAfter: retryAfter}
+	}
+
+	data := struct {
+		Error *struct {
+			Code string  `json:"code"`
+			Lag  float64 `json:"lag"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &data); err != nil || data.Error == nil {
+		return nil
+	}
+	switch data.Error.Code {
+	case "maxlag":
+		metrics.WikipediaReplicationLag.Set(data.Error.Lag)
+		return &throttle{reason: "maxlag", retryAfter: retryAfter, refused: true}
+	case "ratelimited":
+		return &throttle{reason: "ratelimited", retryAfter: retryAfter, refused: true}
+	}
+	return nil
+}
+
+// backoff honours Retry-After when given, otherwise backs off exponentially, both capped at maxBackoff with up to 50% jitter
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := min(retryAfter, maxBackoff)
+	if delay <= 0 {
+		delay = min(time.Duration(1<<attempt)*time.Second, maxBackoff)
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// apiRequest sends a request with maxlag set, retrying while the API asks us to back off, and returns the body.
+// Writes (POST) are only retried when the API refused them, repeating one after an HTTP error could save it twice
+func (w *WikipediaApi) apiRequest(logger *logrus.Entry, method string, params url.Values) ([]byte, error) {
+	params.Set("maxlag", strconv.Itoa(maxLag))
+
+	for attempt := 1; ; attempt++ {
+		var req *http.Request
+		var err error
+		if method == "POST" {
+			req, err = http.NewRequest("POST", apiUrl, strings.NewReader(params.Encode()))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		} else {
+			req, err = http.NewRequest(method, fmt.Sprintf("%s?%s", apiUrl, params.Encode()), nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request: %v", err)
+		}
+		req.Header.Set("User-Agent", "ClueBot/2.1")
+		if w.credentials.Method == AuthOAuth {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.credentials.AccessToken))
+		}
+
+		response, err := w.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(response.Body)
+		if closeErr := response.Body.Close(); closeErr != nil {
+			logrus.Warnf("Failed to close response body: %v", closeErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t := checkThrottle(response, body)
+		if t == nil {
+			if response.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("unexpected response status %d", response.StatusCode)
+			}
+			return body, nil
+		}
+		metrics.WikipediaThrottled.With(prometheus.Labels{"reason": t.reason}).Inc()
+		if method == "POST" && !t.refused {
+			return nil, fmt.Errorf("not retrying write after %s: %w", t.reason, ErrRateLimited)
+		}
+		if attempt >= maxRequestAttempts {
+			return nil, fmt.Errorf("giving up after %d attempts (%s): %w", attempt, t.reason, ErrRateLimited)
+		}
+
+		delay := backoff(attempt, t.retryAfter)
+		logger.Warnf("API asked us to back off (%s), retrying in %s", t.reason, delay)
+		metrics.WikipediaBackoffSeconds.Add(delay.Seconds())
+		time.Sleep(delay)
+	}
+}
+
+// apiCall sends a formatversion=2 request, returning any API error as an *ApiError and decoding the body into out
+func (w *WikipediaApi) apiCall(logger *logrus.Entry, method string, params url.Values, out interface{}) error {
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+
+	body, err := w.apiRequest(logger, method, params)
+	if err != nil {
+		return err
+	}
+
+	envelope := errorResponse{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if envelope.Error != nil {
+		return envelope.Error
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
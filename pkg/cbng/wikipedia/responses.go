@@ -0,0 +1,149 @@
+package wikipedia
+
+import (
+	"fmt"
+	"time"
+)
+
+type errorResponse struct {
+	Error *ApiError `json:"error"`
+}
+
+type loginResponse struct {
+	Login struct {
+		Result string `json:"result"`
+		Token  string `json:"token"`
+		Reason string `json:"reason"`
+	} `json:"login"`
+}
+
+type tokensResponse struct {
+	Query struct {
+		Tokens struct {
+			CsrfToken     string `json:"csrftoken"`
+			RollbackToken string `json:"rollbacktoken"`
+		} `json:"tokens"`
+	} `json:"query"`
+}
+
+type revisionSlotResponse struct {
+	ContentModel string  `json:"contentmodel"`
+	Content      *string `json:"content"`
+	TextHidden   bool    `json:"texthidden"`
+	TextMissing  bool    `json:"textmissing"`
+}
+
+type revisionResponse struct {
+	RevId      int64                           `json:"revid"`
+	User       string                          `json:"user"`
+	UserHidden bool                            `json:"userhidden"`
+	Suppressed bool                            `json:"suppressed"`
+	Timestamp  string                          `json:"timestamp"`
+	Comment    string                          `json:"comment"`
+	Size       int64                           `json:"size"`
+	Sha1       string                          `json:"sha1"`
+	Slots      map[string]revisionSlotResponse `json:"slots"`
+}
+
+type protectionResponse struct {
+	Type    string `json:"type"`
+	Level   string `json:"level"`
+	Expiry  string `json:"expiry"`
+	Cascade bool   `json:"cascade"`
+	Source  string `json:"source"`
+}
+
+type pageResponse struct {
+	Ns         int64                `json:"ns"`
+	Title      string               `json:"title"`
+	Missing    bool                 `json:"missing"`
+	Invalid    bool                 `json:"invalid"`
+	Protection []protectionResponse `json:"protection"`
+	Revisions  []revisionResponse   `json:"revisions"`
+}
+
+func (p *pageResponse) protection() []Protection {
+	protection := []Protection{}
+	for _, entry := range p.Protection {
+		protection = append(protection, Protection{Type: entry.Type, Level: entry.Level, Expiry: entry.Expiry, Source: entry.Source})
+	}
+	return protection
+}
+
+type pagesResponse struct {
+	Query struct {
+		BadRevIds map[string]interface{} `json:"badrevids"`
+		Pages     []pageResponse         `json:"pages"`
+	} `json:"query"`
+}
+
+type editResponse struct {
+	Edit struct {
+		Result   string `json:"result"`
+		NewRevId int64  `json:"newrevid"`
+	} `json:"edit"`
+}
+
+type rollbackResponse struct {
+	Rollback struct {
+		Title    string `json:"title"`
+		RevId    int64  `json:"revid"`
+		OldRevId int64  `json:"old_revid"`
+	} `json:"rollback"`
+}
+
+func parseTimestamp(value string) (int64, error) {
+	t, err := time.Parse("2006-01-02T15:04:05Z", value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode timestamp (%s): %v", value, err)
+	}
+	return t.Unix(), nil
+}
+
+// page returns the single page in the response, or ErrNotFound if it does not exist
+func (r *pagesResponse) page() (*pageResponse, error) {
+	if len(r.Query.BadRevIds) > 0 || len(r.Query.Pages) == 0 {
+		return nil, ErrNotFound
+	}
+	page := r.Query.Pages[0]
+	if page.Missing || page.Invalid {
+		return nil, ErrNotFound
+	}
+	return &page, nil
+}
+
+// hidden returns why the revision was deleted, distinguishing suppression (oversight) from revision deletion
+func (r *revisionResponse) hidden(reason error) error {
+	if r.Suppressed {
+		return fmt.Errorf("revision %d: %w", r.RevId, ErrSuppressed)
+	}
+	return fmt.Errorf("revision %d: %w", r.RevId, reason)
+}
+
+// toRevision converts the response, requiring visible content when withContent is set; a hidden user is only
+// flagged, as it matters for the scored revision but not for the previous revision or talk pages
+func (r *revisionResponse) toRevision(withContent bool) (Revision, error) {
+	revision := Revision{Id: r.RevId, User: r.User, Sha1: r.Sha1, UserHidden: r.UserHidden}
+
+	timestamp, err := parseTimestamp(r.Timestamp)
+	if err != nil {
+		return revision, err
+	}
+	revision.Timestamp = timestamp
+
+	if withContent {
+		main, ok := r.Slots["main"]
+		if !ok {
+			return revision, fmt.Errorf("no main slot for revision %d: %w", r.RevId, ErrIncompleteData)
+		}
+		revision.ContentModel = main.ContentModel
+		if main.TextHidden {
+			return revision, r.hidden(ErrTextHidden)
+		}
+		if main.TextMissing || main.Content == nil {
+			return revision, fmt.Errorf("no content for revision %d: %w", r.RevId, ErrIncompleteData)
+		}
+		revision.Data = *main.Content
+	}
+	return revision, nil
+}
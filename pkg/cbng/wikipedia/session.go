@@ -0,0 +1,79 @@
+package wikipedia
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const maxLoginAttempts = 5
+
+// Each call is retried at most this many times after re-logging in
+const maxSessionRetries = 2
+
+func (w *WikipediaApi) sessionGeneration() int64 {
+	w.sessionLock.Lock()
+	defer w.sessionLock.Unlock()
+	return w.generation
+}
+
+// relogin logs in again unless another caller already did so since generation was observed
+func (w *WikipediaApi) relogin(logger *logrus.Entry, generation int64) error {
+	w.sessionLock.Lock()
+	defer w.sessionLock.Unlock()
+
+	if w.generation != generation {
+		logger.Debugf("Session already renewed by another request")
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxLoginAttempts; attempt++ {
+		if err = w.login(); err == nil {
+			w.generation++
+			w.invalidateTokens()
+			metrics.WikipediaLogin.With(prometheus.Labels{"status": "success"}).Inc()
+			if err := w.loadUserRights(logger); err != nil {
+				logger.Warnf("Failed to load user rights, keeping any loaded at the previous login: %v", err)
+			}
+			return nil
+		}
+		metrics.WikipediaLogin.With(prometheus.Labels{"status": "failed"}).Inc()
+		if attempt == maxLoginAttempts {
+			break
+		}
+
+		delay := backoff(attempt, 0)
+		logger.Warnf("Login attempt %d failed, retrying in %s: %v", attempt, delay, err)
+		time.Sleep(delay)
+	}
+	return fmt.Errorf("giving up login after %d attempts: %v: %w", maxLoginAttempts, err, ErrNotLoggedIn)
+}
+
+// withSession runs call, refreshing the tokens when they went stale and re-logging in when the session has expired
+func (w *WikipediaApi) withSession(logger *logrus.Entry, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		generation := w.sessionGeneration()
+		err := call()
+		if !errors.Is(err, ErrNotLoggedIn) && !errors.Is(err, ErrBadToken) {
+			return err
+		}
+		if attempt >= maxSessionRetries {
+			return err
+		}
+
+		if errors.Is(err, ErrBadToken) && attempt == 0 {
+			logger.Warnf("Got bad token, re-trying with fresh tokens")
+			w.invalidateTokens()
+			continue
+		}
+
+		logger.Warnf("Session expired (%v), re-trying after login", err)
+		if err := w.relogin(logger, generation); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package wikipedia
+
+import (
+	"context"
+	"fmt"
+	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"net/url"
+)
+
+type tokens struct {
+	csrf     string
+	rollback string
+}
+
+// getTokens returns the cached tokens, fetching both types in one request when there are none
+func (w *WikipediaApi) getTokens(l *logrus.Entry, ctx context.Context) (tokens, error) {
+	logger := l.WithField("function", "wikipedia.WikipediaApi.getTokens")
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.getTokens")
+	defer span.End()
+
+	w.tokenLock.Lock()
+	defer w.tokenLock.Unlock()
+
+	if w.tokens != nil {
+		metrics.WikipediaTokenCache.With(prometheus.Labels{"status": "hit"}).Inc()
+		return *w.tokens, nil
+	}
+	metrics.WikipediaTokenCache.With(prometheus.Labels{"status": "miss"}).Inc()
+
+	logger.Tracef("Starting request")
+	data := tokensResponse{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action": []string{"query"},
+		"meta":   []string{"tokens"},
+		"type":   []string{"csrf|rollback"},
+		"assert": []string{"user"},
+	}, &data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return tokens{}, fmt.Errorf("failed to request tokens: %w", err)
+	}
+	logger.Tracef("Got response")
+
+	if data.Query.Tokens.CsrfToken == "" || data.Query.Tokens.RollbackToken == "" {
+		return tokens{}, fmt.Errorf("no tokens returned: %w", ErrIncompleteData)
+	}
+	w.tokens = &tokens{csrf: data.Query.Tokens.CsrfToken, rollback: data.Query.Tokens.RollbackToken}
+	return *w.tokens, nil
+}
+
+func (w *WikipediaApi) invalidateTokens() {
+	w.tokenLock.Lock()
+	defer w.tokenLock.Unlock()
+	w.tokens = nil
+}
+
+func (w *WikipediaApi) getRollbackToken(l *logrus.Entry, ctx context.Context) (string, error) {
+	t, err := w.getTokens(l, ctx)
+	return t.rollback, err
+}
+
+func (w *WikipediaApi) getCsrfToken(l *logrus.Entry, ctx context.Context) (string, error) {
+	t, err := w.getTokens(l, ctx)
+	return t.csrf, err
+}
@@ -2,18 +2,20 @@ package wikipedia
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/cluebotng/botng/pkg/cbng/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,10 +27,30 @@ type RevisionData struct {
 type RevisionHistory []Revision
 
 type Revision struct {
-	Id        int64
-	Timestamp int64
-	Data      string
-	User      string
+	Id           int64
+	Timestamp    int64
+	Data         string
+	User         string
+	Sha1         string
+	ContentModel string
+	UserHidden   bool
+	Protection   []Protection
+}
+
+const ContentModelWikitext = "wikitext"
+
+const editConflictAttempts = 3
+
+type WarningLevel struct {
+	Level    int
+	Template string
+	Time     time.Time
+}
+
+type Section struct {
+	Level int
+	Line  string
+	Index string
 }
 
 type RevisionMeta struct {
@@ -40,14 +62,39 @@ type RevisionMeta struct {
 	Timestamp   int64
 }
 
+// EditOptions are applied to every rollback and edit, so the bot's changes can be filtered on-wiki
+type EditOptions struct {
+	Tags      []string
+	MarkBot   bool
+	Watchlist string
+}
+
+func (o EditOptions) apply(values url.Values, markBotParam string) {
+	if len(o.Tags) > 0 {
+		values.Set("tags", strings.Join(o.Tags, "|"))
+	}
+	if o.MarkBot {
+		values.Set(markBotParam, "1")
+	}
+	if o.Watchlist != "" {
+		values.Set("watchlist", o.Watchlist)
+	}
+}
+
 type WikipediaApi struct {
-	username string
-	password string
-	readOnly bool
-	client   *http.Client
+	rightsLock  sync.RWMutex
+	rights      []string
+	credentials Credentials
+	options     EditOptions
+	readOnly    bool
+	client      *http.Client
+	sessionLock sync.Mutex
+	generation  int64
+	tokenLock   sync.Mutex
+	tokens      *tokens
 }
 
-func NewWikipediaApi(username, password string, readOnly bool) *WikipediaApi {
+func NewWikipediaApi(credentials Credentials, options EditOptions, readOnly bool) *WikipediaApi {
 	logger := logrus.WithField("function", "wikipedia.NewWikipediaApi")
 
 	cookieJar, err := cookiejar.New(nil)
@@ -66,155 +113,113 @@ func NewWikipediaApi(username, password string, readOnly bool) *WikipediaApi {
 		},
 	}
 
-	api := WikipediaApi{
-		username: username,
-		password: password,
-		readOnly: readOnly,
-		client:   client,
+	api := &WikipediaApi{
+		credentials: credentials,
+		options:     options,
+		readOnly:    readOnly,
+		client:      client,
 	}
-	if err := api.login(); err != nil {
-		logger.Panicf("Failed to login to wikipedia: %v", err)
+	if err := api.relogin(logger, api.generation); err != nil {
+		logger.Errorf("Failed to login to wikipedia, will retry on the next write: %v", err)
 	}
-	return &api
+	return api
 }
 
-func (w *WikipediaApi) attemptLogin(reqData url.Values) (bool, *string) {
-	logger := logrus.WithField("function", "wikipedia.WikipediaApi.attemptLogin")
-
-	logger.Tracef("Attempting login")
-	req, err := http.NewRequest("POST", "https://en.wikipedia.org/w/api.php", strings.NewReader(reqData.Encode()))
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return false, nil
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
-	if err != nil {
-		logger.Errorf("Failed to login: %v", err)
-		return false, nil
-	}
-
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
-		}
-	}()
-
-	if response.StatusCode != 200 || response.Body == nil {
-		logger.Errorf("Error response received: %+v", response)
-		return false, nil
-	}
-
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		logger.Errorf("Failed to read token login response: %v", err)
-		return false, nil
-	}
+func (w *WikipediaApi) GetRevisionMetadata(l *logrus.Entry, revId int64) (*RevisionMeta, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.GetRevisionMetadata",
+		"args": map[string]interface{}{
+			"revId": revId,
+		},
+	})
 
-	result := data["login"].(map[string]interface{})["result"].(string)
-	if result == "Success" {
-		logger.Tracef("Got Success")
-		return true, nil
+	logger.Tracef("Starting request")
+	data := pagesResponse{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action": []string{"query"},
+		"prop":   []string{"revisions"},
+		"revids": []string{strconv.FormatInt(revId, 10)},
+		"rvprop": []string{"ids|user|comment|size|timestamp"},
+	}, &data); err != nil {
+		return nil, fmt.Errorf("failed to query revision meta (%d): %w", revId, err)
 	}
 
-	if data["login"].(map[string]interface{})["result"].(string) == "NeedToken" {
-		logger.Tracef("Got NeedToken")
-		loginToken := data["login"].(map[string]interface{})["token"].(string)
-		return false, &loginToken
+	page, err := data.page()
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", revId, err)
 	}
-	return false, nil
-}
-
-func (w *WikipediaApi) login() error {
-	logger := logrus.WithField("function", "wikipedia.WikipediaApi.login")
-	success, loginToken := w.attemptLogin(url.Values{
-		"action":     []string{"login"},
-		"format":     []string{"json"},
-		"lgname":     []string{w.username},
-		"lgpassword": []string{w.password},
-	})
-	if success {
-		logger.Debug("Logged into Wikipedia (no token)")
-		return nil
+	if len(page.Revisions) == 0 {
+		return nil, fmt.Errorf("no revisions found for %d: %w", revId, ErrIncompleteData)
 	}
 
-	if loginToken != nil {
-		success, _ = w.attemptLogin(url.Values{
-			"action":     []string{"login"},
-			"format":     []string{"json"},
-			"lgname":     []string{w.username},
-			"lgpassword": []string{w.password},
-			"lgtoken":    []string{*loginToken},
-		})
-		if success {
-			logger.Debug("Logged into Wikipedia (token)")
-			return nil
-		}
+	targetRevision := page.Revisions[0]
+	timestamp, err := parseTimestamp(targetRevision.Timestamp)
+	if err != nil {
+		return nil, err
 	}
 
-	return errors.New("failed to login to Wikipedia")
+	return &RevisionMeta{
+		NamespaceId: page.Ns,
+		Title:       page.Title,
+		User:        targetRevision.User,
+		Comment:     targetRevision.Comment,
+		Size:        targetRevision.Size,
+		Timestamp:   timestamp,
+	}, nil
 }
 
-func (w *WikipediaApi) GetRevisionMetadata(l *logrus.Entry, revId int64) *RevisionMeta {
+func (w *WikipediaApi) GetUserContributions(l *logrus.Entry, ctx context.Context, user string, limit int) ([]int64, error) {
 	logger := l.WithFields(logrus.Fields{
-		"function": "wikipedia.WikipediaApi.GetRevisionMetadata",
+		"function": "wikipedia.WikipediaApi.GetUserContributions",
 		"args": map[string]interface{}{
-			"revId": revId,
+			"user":  user,
+			"limit": limit,
 		},
 	})
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetUserContributions")
+	defer span.End()
 
-	logger.Tracef("Starting request")
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://en.wikipedia.org/w/api.php?action=query&prop=revisions&revids=%d&rvprop=user|comment|size|timestamp&format=json", revId), nil)
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return nil
-	}
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
-	if err != nil {
-		logger.Errorf("Failed to query revision meta (%d): %v", revId, err)
-		return nil
-	}
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
+	type userContributionsResponse struct {
+		Continue map[string]string `json:"continue"`
+		Query    struct {
+			UserContribs []struct {
+				RevId int64 `json:"revid"`
+			} `json:"usercontribs"`
+		} `json:"query"`
+	}
+
+	revisionIds := []int64{}
+	continueParams := map[string]string{"continue": ""}
+	for len(revisionIds) < limit && continueParams != nil {
+		params := url.Values{
+			"action":  []string{"query"},
+			"list":    []string{"usercontribs"},
+			"ucuser":  []string{user},
+			"ucprop":  []string{"ids"},
+			"uclimit": []string{strconv.Itoa(min(limit-len(revisionIds), 500))},
 		}
-	}()
-
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		logger.Errorf("Failed to read revision meta (%d): %v", revId, err)
-		return nil
-	}
-
-	for _, pages := range data["query"].(map[string]interface{})["pages"].(map[string]interface{}) {
-		if pages.(map[string]interface{})["revisions"] == nil {
-			logger.Errorf("Found no revisions for %v: %v", revId, err)
-			return nil
+		for key, value := range continueParams {
+			params.Set(key, value)
 		}
 
-		targetRevision := pages.(map[string]interface{})["revisions"].([]interface{})[0].(map[string]interface{})
-
-		timestamp, err := time.Parse("2006-01-02T15:04:05Z", targetRevision["timestamp"].(string))
-		if err != nil {
-			logger.Errorf("Failed to parse timestamp (%s): %v", targetRevision["timestamp"], err)
-			return nil
+		logger.Tracef("Starting request")
+		data := userContributionsResponse{}
+		if err := w.apiCall(logger, "GET", params, &data); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			logger.Errorf("Failed to query user contributions (%s): %v", user, err)
+			return nil, err
 		}
+		logger.Tracef("Got response")
 
-		return &RevisionMeta{
-			NamespaceId: int64(pages.(map[string]interface{})["ns"].(float64)),
-			Title:       pages.(map[string]interface{})["title"].(string),
-			User:        targetRevision["user"].(string),
-			Comment:     targetRevision["comment"].(string),
-			Size:        int64(targetRevision["size"].(float64)),
-			Timestamp:   timestamp.Unix(),
+		for _, contribution := range data.Query.UserContribs {
+			revisionIds = append(revisionIds, contribution.RevId)
 		}
+		continueParams = data.Continue
 	}
-	return nil
+	return revisionIds, nil
 }
 
-func (w *WikipediaApi) GetRevisionHistory(l *logrus.Entry, ctx context.Context, page string, revId int64) *RevisionHistory {
+func (w *WikipediaApi) GetRevisionHistory(l *logrus.Entry, ctx context.Context, page string, revId int64) (RevisionHistory, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "wikipedia.WikipediaApi.GetRevisionHistory",
 		"args": map[string]interface{}{
@@ -226,286 +231,226 @@ func (w *WikipediaApi) GetRevisionHistory(l *logrus.Entry, ctx context.Context,
 	defer span.End()
 
 	logger.Tracef("Starting request")
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://en.wikipedia.org/w/api.php?action=query&rawcontinue=1&prop=revisions&titles=%s&rvstartid=%d&rvlimit=5&rvslots=*&rvprop=timestamp|user|content|ids&format=json", url.QueryEscape(page), revId), nil)
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return nil
-	}
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
-	if err != nil {
+	data := pagesResponse{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action":    []string{"query"},
+		"prop":      []string{"revisions"},
+		"titles":    []string{page},
+		"rvstartid": []string{strconv.FormatInt(revId, 10)},
+		"rvlimit":   []string{"5"},
+		"rvslots":   []string{"main"},
+		"rvprop":    []string{"timestamp|user|content|contentmodel|ids|sha1"},
+	}, &data); err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to query page revisions (%s, %d): %v", page, revId, err)
-		return nil
+		return nil, fmt.Errorf("failed to query page revisions (%s, %d): %w", page, revId, err)
 	}
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
-		}
-	}()
+	logger.Tracef("Got response")
 
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		logger.Errorf("Failed to read page revisions (%s, %d): %v", page, revId, err)
-		span.SetStatus(codes.Error, err.Error())
-		return nil
+	pageData, err := data.page()
+	if err != nil {
+		return nil, fmt.Errorf("page %s: %w", page, err)
+	}
+	if len(pageData.Revisions) == 0 {
+		return nil, fmt.Errorf("no revisions found for %s: %w", page, ErrIncompleteData)
 	}
-	logger.Tracef("Got response")
 
 	revisions := RevisionHistory{}
-	for _, value := range data["query"].(map[string]interface{})["pages"].(map[string]interface{}) {
-		if value.(map[string]interface{})["revisions"] == nil {
-			return nil
-		}
-		for _, revision := range value.(map[string]interface{})["revisions"].([]interface{}) {
-			revisionData := Revision{}
-			revisionData.Data = revision.(map[string]interface{})["slots"].(map[string]interface{})["main"].(map[string]interface{})["*"].(string)
-			revisionData.Id = int64(revision.(map[string]interface{})["revid"].(float64))
-			revisionData.User = revision.(map[string]interface{})["user"].(string)
-			timestampCurrent := revision.(map[string]interface{})["timestamp"].(string)
-			if val, err := time.Parse("2006-01-02T15:04:05Z", timestampCurrent); err != nil {
-				span.SetStatus(codes.Error, err.Error())
-				logger.Errorf("Failed to decode revision timestamp (%s): %v", timestampCurrent, err)
-			} else {
-				revisionData.Timestamp = val.Unix()
-			}
-			revisions = append(revisions, revisionData)
+	for _, revision := range pageData.Revisions {
+		revisionData, err := revision.toRevision(true)
+		if errors.Is(err, ErrTextHidden) || errors.Is(err, ErrSuppressed) {
+			// Deleted revisions still count towards the history, only their content is unavailable
+			logger.Debugf("Keeping partial revision: %v", err)
+		} else if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
 		}
+		revisions = append(revisions, revisionData)
 	}
-	return &revisions
+	return revisions, nil
 }
 
-func (w *WikipediaApi) GetRevision(l *logrus.Entry, ctx context.Context, page string, revId int64) *RevisionData {
+// GetTopRevision returns the metadata (no content) of the current revision of a page
+func (w *WikipediaApi) GetTopRevision(l *logrus.Entry, ctx context.Context, title string) (*Revision, error) {
 	logger := l.WithFields(logrus.Fields{
-		"function": "wikipedia.WikipediaApi.GetRevision",
+		"function": "wikipedia.WikipediaApi.GetTopRevision",
 		"args": map[string]interface{}{
-			"page":  page,
-			"revId": revId,
+			"title": title,
 		},
 	})
-	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetRevisionHistory")
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetTopRevision")
 	defer span.End()
 
 	logger.Tracef("Starting request")
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://en.wikipedia.org/w/api.php?action=query&rawcontinue=1&prop=revisions&titles=%s&rvstartid=%d&rvlimit=2&rvslots=*&rvprop=timestamp|user|content|ids&format=json", url.QueryEscape(page), revId), nil)
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return nil
-	}
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
-	if err != nil {
+	data := pagesResponse{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action":  []string{"query"},
+		"prop":    []string{"revisions"},
+		"titles":  []string{title},
+		"rvlimit": []string{"1"},
+		"rvprop":  []string{"ids|timestamp|user|sha1"},
+	}, &data); err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to query page revisions: %v", err)
-		return nil
-	}
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
-		}
-	}()
-
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to read page revisions: %v", err)
-		return nil
+		return nil, fmt.Errorf("failed to query top revision (%s): %w", title, err)
 	}
 	logger.Tracef("Got response")
 
-	if data["query"] == nil {
-		logger.Errorf("Found no query result: %v", data)
-		return nil
+	page, err := data.page()
+	if err != nil {
+		return nil, fmt.Errorf("page %s: %w", title, err)
+	}
+	if len(page.Revisions) == 0 {
+		return nil, fmt.Errorf("no revisions found for %s: %w", title, ErrIncompleteData)
 	}
 
-	for _, value := range data["query"].(map[string]interface{})["pages"].(map[string]interface{}) {
-		if value.(map[string]interface{})["revisions"] == nil {
-			logger.Errorf("Found no pages: %v", data)
-			return nil
-		}
-		revisions := value.(map[string]interface{})["revisions"].([]interface{})
-
-		if len(revisions) != 2 {
-			logger.Warnf("Not enough revisions: %v", data)
-			return nil
-		}
-		revisionData := RevisionData{}
-
-		currentData := revisions[0].(map[string]interface{})["slots"].(map[string]interface{})["main"].(map[string]interface{})["*"]
-		if currentData == nil {
-			logger.Warnf("No current revision data found: %+v", revisions[0])
-			return nil
-		}
-
-		revisionData.Current.Data = currentData.(string)
-		revisionData.Current.Id = int64(revisions[0].(map[string]interface{})["revid"].(float64))
-		revisionData.Current.User = revisions[0].(map[string]interface{})["user"].(string)
-		timestampCurrent := revisions[0].(map[string]interface{})["timestamp"].(string)
-
-		previousTime, err := time.Parse("2006-01-02T15:04:05Z", timestampCurrent)
-		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Warnf("Failed to decode revision timestamp (%s): %v", timestampCurrent, err)
-			return nil
-		}
-		revisionData.Current.Timestamp = previousTime.Unix()
-
-		previousData := revisions[1].(map[string]interface{})["slots"].(map[string]interface{})["main"].(map[string]interface{})["*"]
-		if previousData == nil {
-			logger.Warnf("No previous revision data found: %+v", revisions[1])
-			return nil
-		}
-		revisionData.Previous.Data = previousData.(string)
-		revisionData.Previous.Id = int64(revisions[1].(map[string]interface{})["revid"].(float64))
-		revisionData.Previous.User = revisions[1].(map[string]interface{})["user"].(string)
-		timestampPrevious := revisions[1].(map[string]interface{})["timestamp"].(string)
-
-		currentTime, err := time.Parse("2006-01-02T15:04:05Z", timestampPrevious)
-		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Warnf("Failed to decode revision timestamp (%s): %v", timestampPrevious, err)
-			return nil
-		}
-		revisionData.Previous.Timestamp = currentTime.Unix()
-
-		return &revisionData
+	revision, err := page.Revisions[0].toRevision(false)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &revision, nil
 }
 
-func (w *WikipediaApi) GetPage(l *logrus.Entry, ctx context.Context, name string) *Revision {
+func (w *WikipediaApi) GetRevision(l *logrus.Entry, ctx context.Context, page string, revId int64) (*RevisionData, error) {
 	logger := l.WithFields(logrus.Fields{
-		"function": "wikipedia.WikipediaApi.GetPage",
+		"function": "wikipedia.WikipediaApi.GetRevision",
 		"args": map[string]interface{}{
-			"name": name,
+			"page":  page,
+			"revId": revId,
 		},
 	})
-	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetPage")
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetRevision")
 	defer span.End()
 
 	logger.Tracef("Starting request")
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://en.wikipedia.org/w/api.php?action=query&rawcontinue=1&prop=revisions&titles=%s&rvlimit=1&rvslots=*&rvprop=timestamp|user|content|ids&format=json&meta=userinfo&rvdir=older", url.QueryEscape(name)), nil)
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return nil
+	data := pagesResponse{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action":    []string{"query"},
+		"prop":      []string{"revisions|info"},
+		"inprop":    []string{"protection"},
+		"titles":    []string{page},
+		"rvstartid": []string{strconv.FormatInt(revId, 10)},
+		"rvlimit":   []string{"2"},
+		"rvslots":   []string{"main"},
+		"rvprop":    []string{"timestamp|user|content|contentmodel|ids"},
+	}, &data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to query page revisions (%s, %d): %w", page, revId, err)
 	}
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
+	logger.Tracef("Got response")
+
+	pageData, err := data.page()
 	if err != nil {
-		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to query page revisions %s: %v", name, err)
-		return nil
+		return nil, fmt.Errorf("page %s: %w", page, err)
+	}
+	if len(pageData.Revisions) != 2 {
+		return nil, fmt.Errorf("expected 2 revisions for %s, got %d: %w", page, len(pageData.Revisions), ErrIncompleteData)
 	}
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
-		}
-	}()
 
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+	current, err := pageData.Revisions[0].toRevision(true)
+	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to read page revisions %s: %v", name, err)
-		return nil
+		return nil, err
 	}
-	logger.Tracef("Got response")
-
-	for _, value := range data["query"].(map[string]interface{})["pages"].(map[string]interface{}) {
-		if value.(map[string]interface{})["revisions"] == nil {
-			logger.Errorf("Found no revisions for %v: %v", name, err)
-			return nil
-		}
-		revisions := value.(map[string]interface{})["revisions"].([]interface{})
-
-		revisionData := Revision{}
-		revisionData.Data = revisions[0].(map[string]interface{})["slots"].(map[string]interface{})["main"].(map[string]interface{})["*"].(string)
-		revisionData.Id = int64(revisions[0].(map[string]interface{})["revid"].(float64))
-		revisionData.User = revisions[0].(map[string]interface{})["user"].(string)
-		timestampCurrent := revisions[0].(map[string]interface{})["timestamp"].(string)
-		if val, err := time.Parse("2006-01-02T15:04:05Z", timestampCurrent); err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Errorf("Failed to decode revision timestamp (%s): %v", timestampCurrent, err)
-		} else {
-			revisionData.Timestamp = val.Unix()
-		}
-		return &revisionData
+	previous, err := pageData.Revisions[1].toRevision(true)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
-	return nil
+	current.Protection = pageData.protection()
+	previous.Protection = current.Protection
+	return &RevisionData{Current: current, Previous: previous}, nil
 }
 
-func (w *WikipediaApi) getRollbackToken(l *logrus.Entry, ctx context.Context) *string {
-	logger := l.WithField("function", "wikipedia.WikipediaApi.getRollbackToken")
-	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.getRollbackToken")
+// GetRevisionsById returns the revisions (with content) that could be fully loaded, keyed by id
+func (w *WikipediaApi) GetRevisionsById(l *logrus.Entry, ctx context.Context, revIds []int64) (map[int64]Revision, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.GetRevisionsById",
+		"args": map[string]interface{}{
+			"revIds": revIds,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetRevisionsById")
 	defer span.End()
 
-	logger.Tracef("Starting request")
-	req, err := http.NewRequest("GET", "https://en.wikipedia.org/w/api.php?action=query&meta=tokens&type=rollback&format=json", nil)
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return nil
+	ids := []string{}
+	for _, revId := range revIds {
+		ids = append(ids, strconv.FormatInt(revId, 10))
 	}
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
-	if err != nil {
-		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to request rollback token: %v", err)
-		return nil
-	}
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
-		}
-	}()
 
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+	logger.Tracef("Starting request")
+	data := pagesResponse{}
+	if err := w.apiCall(logger, "POST", url.Values{
+		"action":  []string{"query"},
+		"prop":    []string{"revisions|info"},
+		"inprop":  []string{"protection"},
+		"revids":  []string{strings.Join(ids, "|")},
+		"rvslots": []string{"main"},
+		"rvprop":  []string{"timestamp|user|content|contentmodel|ids"},
+	}, &data); err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to read rollback token response: %v", err)
-		return nil
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
 	}
 	logger.Tracef("Got response")
 
-	token := data["query"].(map[string]interface{})["tokens"].(map[string]interface{})["rollbacktoken"].(string)
-	return &token
+	revisions := map[int64]Revision{}
+	for _, page := range data.Query.Pages {
+		for _, revision := range page.Revisions {
+			revisionData, err := revision.toRevision(true)
+			if err != nil {
+				// Large responses are truncated, the caller falls back to fetching the revision alone
+				logger.Debugf("Skipping revision %d: %v", revision.RevId, err)
+				continue
+			}
+			revisionData.Protection = page.protection()
+			revisions[revisionData.Id] = revisionData
+		}
+	}
+	return revisions, nil
 }
 
-func (w *WikipediaApi) getCsrfToken(l *logrus.Entry, ctx context.Context) *string {
-	logger := l.WithField("function", "wikipedia.WikipediaApi.getCsrfToken")
-	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.getCsrfToken")
+// GetPage returns the current revision of a page, or ErrNotFound if it does not exist
+func (w *WikipediaApi) GetPage(l *logrus.Entry, ctx context.Context, name string) (*Revision, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.GetPage",
+		"args": map[string]interface{}{
+			"name": name,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.GetPage")
 	defer span.End()
 
 	logger.Tracef("Starting request")
-	req, err := http.NewRequest("GET", "https://en.wikipedia.org/w/api.php?action=query&meta=tokens&format=json", nil)
-	if err != nil {
-		logger.Errorf("Failed to build request: %v", err)
-		return nil
+	data := pagesResponse{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action":  []string{"query"},
+		"prop":    []string{"revisions"},
+		"titles":  []string{name},
+		"rvlimit": []string{"1"},
+		"rvslots": []string{"main"},
+		"rvprop":  []string{"timestamp|user|content|contentmodel|ids"},
+		"rvdir":   []string{"older"},
+	}, &data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to query page revisions %s: %w", name, err)
 	}
-	req.Header.Set("User-Agent", "ClueBot/2.1")
-	response, err := w.client.Do(req)
+	logger.Tracef("Got response")
+
+	page, err := data.page()
 	if err != nil {
-		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to request csrf token: %v", err)
-		return nil
+		return nil, fmt.Errorf("page %s: %w", name, err)
+	}
+	if len(page.Revisions) == 0 {
+		return nil, fmt.Errorf("no revisions found for %s: %w", name, ErrIncompleteData)
 	}
-	defer func() {
-		if err := response.Body.Close(); err != nil {
-			logrus.Warnf("Failed to close response body: %v", err)
-		}
-	}()
 
-	data := map[string]interface{}{}
-	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+	revision, err := page.Revisions[0].toRevision(true)
+	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		logger.Errorf("Failed to read csrf token response: %v", err)
-		return nil
+		return nil, err
 	}
-	logger.Tracef("Got response")
-
-	token := data["query"].(map[string]interface{})["tokens"].(map[string]interface{})["csrftoken"].(string)
-	return &token
+	return &revision, nil
 }
 
-func (w *WikipediaApi) Rollback(l *logrus.Entry, parentCtx context.Context, title, user, comment string) bool {
+func (w *WikipediaApi) Rollback(l *logrus.Entry, parentCtx context.Context, title, user, comment string) error {
 	logger := l.WithFields(logrus.Fields{
 		"function": "wikipedia.WikipediaApi.Rollback",
 		"args": map[string]interface{}{
@@ -517,95 +462,90 @@ func (w *WikipediaApi) Rollback(l *logrus.Entry, parentCtx context.Context, titl
 	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.Rollback")
 	defer span.End()
 
-	rollbackToken := w.getRollbackToken(logger, ctx)
-	if rollbackToken == nil {
-		logger.Errorf("Failed to get token for rolling back %v (%v)", title, user)
-		return false
-	}
-
 	if w.readOnly {
 		logger.Infof("Mock rollback due to read only mode")
-	} else {
-		logger.Tracef("Starting request")
-		req, err := http.NewRequest("POST", "https://en.wikipedia.org/w/api.php", strings.NewReader(url.Values{
+		return nil
+	}
+
+	err := w.withSession(logger, func() error {
+		rollbackToken, err := w.getRollbackToken(logger, ctx)
+		if err != nil {
+			return err
+		}
+
+		values := url.Values{
 			"action":  []string{"rollback"},
-			"format":  []string{"json"},
 			"title":   []string{title},
 			"user":    []string{user},
 			"summary": []string{comment},
-			"token":   []string{*rollbackToken},
-		}.Encode()))
+			"token":   []string{rollbackToken},
+			"assert":  []string{"user"},
+		}
+		w.options.apply(values, "markbot")
+
+		logger.Tracef("Starting request")
+		data := rollbackResponse{}
+		if err := w.apiCall(logger, "POST", values, &data); err != nil {
+			return err
+		}
+		logger.Debugf("Completed Rollback: %+v", data)
+		return nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("rollback of %s failed: %w", title, err)
+	}
+	return nil
+}
+
+var warningRegex = regexp.MustCompile(`<!--\s*Template:(uw-[a-z0-9-]*?([1-4])(im)?)\s*-->.*?(\d{2}:\d{2}, \d{1,2} [a-zA-Z]+ \d{4} \(UTC\))`)
+
+// ParseWarningLevel returns the highest uw-* warning on a talk page issued within the window before now
+func ParseWarningLevel(text string, now time.Time, window time.Duration) WarningLevel {
+	level := WarningLevel{}
+	for _, match := range warningRegex.FindAllStringSubmatch(text, -1) {
+		matchLevel, err := strconv.Atoi(match[2])
 		if err != nil {
-			logger.Errorf("Failed to build request: %v", err)
-			return false
+			continue
 		}
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Set("User-Agent", "ClueBot/2.1")
-		response, err := w.client.Do(req)
+		t, err := time.Parse("15:04, 2 January 2006 (UTC)", match[4])
 		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Errorf("Failed to request rollback: %v", err)
-			return false
+			continue
 		}
-		defer func() {
-			if err := response.Body.Close(); err != nil {
-				logrus.Warnf("Failed to close response body: %v", err)
-			}
-		}()
-
-		data := map[string]interface{}{}
-		if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Errorf("Failed to read rollback response: %v", err)
-			return false
+		if t.After(now) || now.Sub(t) > window {
+			continue
 		}
-		logger.Tracef("Got response")
-
-		if data["error"] != nil {
-			if data["error"].(map[string]interface{})["code"].(string) == "badtoken" {
-				logger.Warnf("Got bad token, re-trying after login")
-				if err := w.login(); err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					logger.Panicf("Failed to login to wikipedia: %v", err)
-				}
-				return w.Rollback(logger, ctx, title, user, comment)
-			}
-			logger.Errorf("Error during rollback: %+v", data)
-			return false
+		if matchLevel > level.Level || (matchLevel == level.Level && t.After(level.Time)) {
+			level = WarningLevel{Level: matchLevel, Template: match[1], Time: t}
 		}
-
-		logger.Debugf("Completed Rollback: %+v", data)
 	}
-	return true
+	return level
 }
 
-func (w *WikipediaApi) GetWarningLevel(l *logrus.Entry, parentCtx context.Context, user string) int {
+// GetWarningLevel returns the current warning level of a user, treating a missing talk page as level 0
+func (w *WikipediaApi) GetWarningLevel(l *logrus.Entry, parentCtx context.Context, user string, window time.Duration) (WarningLevel, error) {
 	logger := l.WithFields(logrus.Fields{
 		"function": "wikipedia.WikipediaApi.GetWarningLevel",
 		"args": map[string]interface{}{
-			"user": user,
+			"user":   user,
+			"window": window,
 		},
 	})
 	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.GetWarningLevel")
 	defer span.End()
 
-	level := 0
-	if page := w.GetPage(logger, ctx, fmt.Sprintf("User talk:%s", user)); page != nil {
-		matches := regexp.MustCompile(`<!-- Template:uw-[a-z]*(\d)(im)? -->.*(\d{2}:\d{2}, \d+ [a-zA-Z]+ \d{4} \(UTC\))`).FindAllStringSubmatch(page.Data, -1)
-		for _, match := range matches {
-			if matchLevel, err := strconv.Atoi(match[1]); err == nil {
-				if t, err := time.Parse("15:04, 02 January 2006 (MST)", match[2]); err == nil {
-					if matchLevel > level && t.Second() <= (2*24*60*60) {
-						level = matchLevel
-					}
-				}
-			}
-		}
+	page, err := w.GetPage(logger, ctx, fmt.Sprintf("User talk:%s", user))
+	if errors.Is(err, ErrNotFound) {
+		return WarningLevel{}, nil
 	}
-	return level
+	if err != nil {
+		return WarningLevel{}, err
+	}
+	return ParseWarningLevel(page.Data, time.Now().UTC(), window), nil
 }
 
-func (w *WikipediaApi) AppendToPage(l *logrus.Entry, parentCtx context.Context, title, message, comment string) bool {
+// AppendToPage appends to the end of an existing page, without re-submitting the current content
+func (w *WikipediaApi) AppendToPage(l *logrus.Entry, parentCtx context.Context, title, message, comment string) error {
 	logger := l.WithFields(logrus.Fields{
 		"function": "wikipedia.WikipediaApi.AppendToPage",
 		"args": map[string]interface{}{
@@ -617,86 +557,231 @@ func (w *WikipediaApi) AppendToPage(l *logrus.Entry, parentCtx context.Context,
 	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.AppendToPage")
 	defer span.End()
 
-	page := w.GetPage(logger, ctx, title)
-	if page == nil {
-		logger.Warnf("Could not fetch current page data")
-		return false
-	}
-	newData := fmt.Sprintf("%s\n\n%s", page.Data, message)
-	return w.WritePage(logger, ctx, title, newData, comment)
+	return w.edit(logger, ctx, url.Values{
+		"title":      []string{title},
+		"appendtext": []string{fmt.Sprintf("\n\n%s", message)},
+		"summary":    []string{comment},
+		"nocreate":   []string{"1"},
+	})
 }
 
-func (w *WikipediaApi) WritePage(l *logrus.Entry, parentCtx context.Context, title, content, comment string) bool {
+// WritePage replaces the page content, failing with ErrEditConflict if the page changed after baseTimestamp
+func (w *WikipediaApi) WritePage(l *logrus.Entry, parentCtx context.Context, title, content, comment string, baseTimestamp, startTimestamp int64) error {
 	logger := l.WithFields(logrus.Fields{
 		"function": "wikipedia.WikipediaApi.WritePage",
+		"args": map[string]interface{}{
+			"title":          title,
+			"content":        content,
+			"comment":        comment,
+			"baseTimestamp":  baseTimestamp,
+			"startTimestamp": startTimestamp,
+		},
+	})
+	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.WritePage")
+	defer span.End()
+
+	return w.edit(logger, ctx, url.Values{
+		"title":          []string{title},
+		"text":           []string{content},
+		"summary":        []string{comment},
+		"basetimestamp":  []string{time.Unix(baseTimestamp, 0).UTC().Format("2006-01-02T15:04:05Z")},
+		"starttimestamp": []string{time.Unix(startTimestamp, 0).UTC().Format("2006-01-02T15:04:05Z")},
+	})
+}
+
+// UpdatePage re-reads the page and re-applies transform when the write hits an edit conflict, transform returns the new
+// content and the edit summary so it can decide what to do from the revision the edit is based on
+func (w *WikipediaApi) UpdatePage(l *logrus.Entry, parentCtx context.Context, title string, transform func(current string) (string, string, error)) error {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.UpdatePage",
+		"args": map[string]interface{}{
+			"title": title,
+		},
+	})
+	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.UpdatePage")
+	defer span.End()
+
+	for attempt := 1; attempt <= editConflictAttempts; attempt++ {
+		startTimestamp := time.Now().UTC().Unix()
+		page, err := w.GetPage(logger, ctx, title)
+		if err != nil {
+			return fmt.Errorf("could not fetch current page data: %w", err)
+		}
+
+		content, comment, err := transform(page.Data)
+		if err != nil {
+			return err
+		}
+
+		err = w.WritePage(logger, ctx, title, content, comment, page.Timestamp, startTimestamp)
+		if err == nil || !errors.Is(err, ErrEditConflict) {
+			return err
+		}
+		logger.Infof("Edit conflict on attempt %d, re-applying", attempt)
+		metrics.EditStatus.With(prometheus.Labels{"state": "edit_conflict", "status": "retry"}).Inc()
+	}
+	logger.Warnf("Giving up after %d edit conflicts", editConflictAttempts)
+	metrics.EditStatus.With(prometheus.Labels{"state": "edit_conflict", "status": "failed"}).Inc()
+	return fmt.Errorf("giving up after %d attempts: %w", editConflictAttempts, ErrEditConflict)
+}
+
+// monthlySection returns the level 2 section for header wherever it is on the page, the last one if it is repeated
+func monthlySection(sections []Section, header string) *Section {
+	var found *Section
+	for i := range sections {
+		// Sections transcluded from templates cannot be edited through this page
+		if strings.HasPrefix(sections[i].Index, "T-") {
+			continue
+		}
+		if sections[i].Level == 2 && strings.EqualFold(strings.TrimSpace(sections[i].Line), header) {
+			found = &sections[i]
+		}
+	}
+	return found
+}
+
+// AppendToMonthlySection appends to the "== Month Year ==" section for now, adding it to the end of the page if missing
+func (w *WikipediaApi) AppendToMonthlySection(l *logrus.Entry, parentCtx context.Context, title, content, comment string, now time.Time) error {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.AppendToMonthlySection",
 		"args": map[string]interface{}{
 			"title":   title,
 			"content": content,
 			"comment": comment,
 		},
 	})
-	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.WritePage")
+	ctx, span := metrics.OtelTracer.Start(parentCtx, "wikipedia.AppendToMonthlySection")
 	defer span.End()
 
-	editToken := w.getCsrfToken(logger, ctx)
-	if editToken == nil {
-		logger.Errorf("Failed to get csrf token for %v", title)
-		return false
+	header := now.UTC().Format("January 2006")
+	for attempt := 1; attempt <= editConflictAttempts; attempt++ {
+		startTimestamp := time.Now().UTC().Unix()
+		values := url.Values{
+			"title":          []string{title},
+			"summary":        []string{comment},
+			"starttimestamp": []string{time.Unix(startTimestamp, 0).UTC().Format("2006-01-02T15:04:05Z")},
+		}
+
+		// The sections are read from the same revision the edit is based on, so a change in between is a conflict
+		page, err := w.GetPage(logger, ctx, title)
+		if errors.Is(err, ErrNotFound) {
+			logger.Debugf("Creating page with new section for %s", header)
+			values.Set("section", "new")
+			values.Set("sectiontitle", header)
+			values.Set("text", content)
+			values.Set("createonly", "1")
+		} else if err != nil {
+			return fmt.Errorf("could not fetch current page data: %w", err)
+		} else {
+			sections, err := w.getRevisionSections(logger, ctx, page.Id)
+			if err != nil {
+				return fmt.Errorf("could not fetch current page sections: %w", err)
+			}
+
+			values.Set("basetimestamp", time.Unix(page.Timestamp, 0).UTC().Format("2006-01-02T15:04:05Z"))
+			if section := monthlySection(sections, header); section != nil {
+				logger.Debugf("Appending to existing section %s (%s)", section.Index, section.Line)
+				values.Set("section", section.Index)
+				values.Set("appendtext", fmt.Sprintf("\n\n%s", content))
+				values.Set("nocreate", "1")
+			} else {
+				logger.Debugf("Creating new section for %s", header)
+				values.Set("section", "new")
+				values.Set("sectiontitle", header)
+				values.Set("text", content)
+			}
+		}
+
+		err = w.edit(logger, ctx, values)
+		if err == nil || !errors.Is(err, ErrEditConflict) {
+			return err
+		}
+		logger.Infof("Edit conflict on attempt %d, re-reading sections", attempt)
+		metrics.EditStatus.With(prometheus.Labels{"state": "edit_conflict", "status": "retry"}).Inc()
 	}
+	logger.Warnf("Giving up after %d edit conflicts", editConflictAttempts)
+	metrics.EditStatus.With(prometheus.Labels{"state": "edit_conflict", "status": "failed"}).Inc()
+	return fmt.Errorf("giving up after %d attempts: %w", editConflictAttempts, ErrEditConflict)
+}
 
-	if w.readOnly {
-		logger.Infof("Mock page write due to read only mode")
-	} else {
-		logger.Tracef("Starting request")
-		req, err := http.NewRequest("POST", "https://en.wikipedia.org/w/api.php", strings.NewReader(url.Values{
-			"action":   []string{"edit"},
-			"format":   []string{"json"},
-			"title":    []string{title},
-			"text":     []string{content},
-			"summary":  []string{comment},
-			"token":    []string{*editToken},
-			"notminor": []string{"1"},
-		}.Encode()))
+// getRevisionSections returns the sections of a specific revision of a page
+func (w *WikipediaApi) getRevisionSections(l *logrus.Entry, ctx context.Context, revisionId int64) ([]Section, error) {
+	logger := l.WithFields(logrus.Fields{
+		"function": "wikipedia.WikipediaApi.getRevisionSections",
+		"args": map[string]interface{}{
+			"revisionId": revisionId,
+		},
+	})
+	_, span := metrics.OtelTracer.Start(ctx, "wikipedia.getRevisionSections")
+	defer span.End()
+
+	logger.Tracef("Starting request")
+	data := struct {
+		Parse struct {
+			Sections []struct {
+				Level string `json:"level"`
+				Line  string `json:"line"`
+				Index string `json:"index"`
+			} `json:"sections"`
+		} `json:"parse"`
+	}{}
+	if err := w.apiCall(logger, "GET", url.Values{
+		"action": []string{"parse"},
+		"prop":   []string{"sections"},
+		"oldid":  []string{strconv.FormatInt(revisionId, 10)},
+	}, &data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to query sections: %w", err)
+	}
+	logger.Tracef("Got response")
+
+	sections := []Section{}
+	for _, section := range data.Parse.Sections {
+		level, err := strconv.Atoi(section.Level)
 		if err != nil {
-			logger.Errorf("Failed to build request: %v", err)
-			return false
+			continue
 		}
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Set("User-Agent", "ClueBot/2.1")
-		response, err := w.client.Do(req)
+		sections = append(sections, Section{Level: level, Line: section.Line, Index: section.Index})
+	}
+	return sections, nil
+}
+
+func (w *WikipediaApi) edit(logger *logrus.Entry, ctx context.Context, params url.Values) error {
+	span := trace.SpanFromContext(ctx)
+
+	if w.readOnly {
+		logger.Infof("Mock page write due to read only mode")
+		return nil
+	}
+
+	err := w.withSession(logger, func() error {
+		editToken, err := w.getCsrfToken(logger, ctx)
 		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Errorf("Failed to request edit: %v", err)
-			return false
+			return err
 		}
-		defer func() {
-			if err := response.Body.Close(); err != nil {
-				logrus.Warnf("Failed to close response body: %v", err)
-			}
-		}()
 
-		data := map[string]interface{}{}
-		if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			logger.Errorf("Failed to read edit response: %v", err)
-			return false
+		values := url.Values{
+			"action":   []string{"edit"},
+			"token":    []string{editToken},
+			"notminor": []string{"1"},
+			"assert":   []string{"user"},
 		}
-		logger.Tracef("Got response")
-
-		if data["error"] != nil {
-			if data["error"].(map[string]interface{})["code"].(string) == "badtoken" {
-				logger.Warnf("Got bad token, re-trying after login")
-				if err := w.login(); err != nil {
-					span.SetStatus(codes.Error, err.Error())
-					logger.Panicf("Failed to login to wikipedia: %v", err)
-				}
-				return w.WritePage(logger, ctx, title, content, comment)
-			}
-			logger.Errorf("Error during edit: %+v", data)
-			return false
+		w.options.apply(values, "bot")
+		for key, value := range params {
+			values[key] = value
 		}
 
+		logger.Tracef("Starting request")
+		data := editResponse{}
+		if err := w.apiCall(logger, "POST", values, &data); err != nil {
+			return err
+		}
 		logger.Debugf("Completed edit: %+v", data)
+		return nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("edit of %s failed: %w", params.Get("title"), err)
 	}
-	return true
+	return nil
 }
@@ -0,0 +1,348 @@
+package wikipedia
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func warning(template string, t time.Time) string {
+	return fmt.Sprintf("== %s ==\n<!-- Template:%s --> Please stop. [[User:ClueBot NG|ClueBot NG]] %s\n",
+		t.Format("January 2006"), template, t.Format("15:04, 2 January 2006 (UTC)"))
+}
+
+func TestParseWarningLevel(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	window := 48 * time.Hour
+
+	tests := []struct {
+		name     string
+		text     string
+		level    int
+		template string
+	}{
+		{"empty talk page", "", 0, ""},
+		{"no warnings", "Welcome to Wikipedia! ~~~~", 0, ""},
+		{"single warning", warning("uw-vandalism1", now.Add(-time.Hour)), 1, "uw-vandalism1"},
+		{"highest level wins", warning("uw-vandalism3", now.Add(-2*time.Hour)) + warning("uw-vandalism2", now.Add(-time.Hour)), 3, "uw-vandalism3"},
+		{"latest of the same level wins", warning("uw-test2", now.Add(-2*time.Hour)) + warning("uw-vandalism2", now.Add(-time.Hour)), 2, "uw-vandalism2"},
+		{"immediate final warning", warning("uw-vandalism4im", now.Add(-time.Hour)), 4, "uw-vandalism4im"},
+		{"hyphenated template", warning("uw-delete-summary2", now.Add(-time.Hour)), 2, "uw-delete-summary2"},
+		{"level-less template", warning("uw-npov", now.Add(-time.Hour)), 0, ""},
+		{"unknown level", warning("uw-vandalism5", now.Add(-time.Hour)), 0, ""},
+		{"non uw template", warning("Welcome", now.Add(-time.Hour)), 0, ""},
+		{"inside the window", warning("uw-vandalism2", now.Add(-47*time.Hour)), 2, "uw-vandalism2"},
+		{"at the window cutoff", warning("uw-vandalism2", now.Add(-window)), 2, "uw-vandalism2"},
+		{"outside the window", warning("uw-vandalism2", now.Add(-window-time.Minute)), 0, ""},
+		{"old higher warning ignored", warning("uw-vandalism4", now.Add(-30*24*time.Hour)) + warning("uw-vandalism1", now.Add(-time.Hour)), 1, "uw-vandalism1"},
+		{"future timestamp ignored", warning("uw-vandalism3", now.Add(time.Hour)), 0, ""},
+		{"single digit day", "<!-- Template:uw-vandalism2 --> ~~~~ 09:30, 5 March 2024 (UTC)", 0, ""},
+		{"single digit day in window", "<!-- Template:uw-vandalism2 --> ~~~~ 09:30, 14 March 2024 (UTC)", 2, "uw-vandalism2"},
+		{"invalid timestamp", "<!-- Template:uw-vandalism2 --> ~~~~ 09:30, 14 Marchember 2024 (UTC)", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := ParseWarningLevel(tt.text, now, window)
+			if level.Level != tt.level || level.Template != tt.template {
+				t.Errorf("expected level %d (%s), got %d (%s)", tt.level, tt.template, level.Level, level.Template)
+			}
+		})
+	}
+}
+
+func TestParseWarningLevelDayFormats(t *testing.T) {
+	window := 48 * time.Hour
+	tests := []struct {
+		timestamp string
+		expected  time.Time
+	}{
+		{"09:30, 5 March 2024 (UTC)", time.Date(2024, time.March, 5, 9, 30, 0, 0, time.UTC)},
+		{"23:59, 15 March 2024 (UTC)", time.Date(2024, time.March, 15, 23, 59, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timestamp, func(t *testing.T) {
+			level := ParseWarningLevel("<!-- Template:uw-vandalism1 --> ~~~~ "+tt.timestamp, tt.expected.Add(time.Minute), window)
+			if level.Level != 1 || !level.Time.Equal(tt.expected) {
+				t.Errorf("expected level 1 at %s, got %d at %s", tt.expected, level.Level, level.Time)
+			}
+		})
+	}
+}
+
+func TestMonthlySection(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections []Section
+		index    string
+	}{
+		{"no sections", []Section{}, ""},
+		{"month is the last section", []Section{{2, "February 2024", "1"}, {2, "March 2024", "2"}}, "2"},
+		{"month header case and whitespace", []Section{{2, " march 2024 ", "1"}}, "1"},
+		{"month followed by a subsection", []Section{{2, "March 2024", "1"}, {3, "Reply", "2"}}, "1"},
+		{"month followed by another section", []Section{{2, "March 2024", "1"}, {2, "Question", "2"}}, "1"},
+		{"month followed by a level 1 section", []Section{{2, "March 2024", "1"}, {1, "Archive", "2"}}, "1"},
+		{"repeated month header", []Section{{2, "March 2024", "1"}, {2, "Question", "2"}, {2, "March 2024", "3"}}, "3"},
+		{"month followed by a transcluded section", []Section{{2, "March 2024", "1"}, {2, "Notice", "T-1"}}, "1"},
+		{"transcluded month header", []Section{{2, "March 2024", "T-1"}}, ""},
+		{"month header at level 3", []Section{{2, "Warnings", "1"}, {3, "March 2024", "2"}}, ""},
+		{"previous month", []Section{{2, "February 2024", "1"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := monthlySection(tt.sections, "March 2024")
+			if tt.index == "" {
+				if section != nil {
+					t.Errorf("expected a new section, got %+v", section)
+				}
+				return
+			}
+			if section == nil || section.Index != tt.index {
+				t.Errorf("expected section %s, got %+v", tt.index, section)
+			}
+		})
+	}
+}
+
+func TestToRevisionHiddenContent(t *testing.T) {
+	content := "Some text"
+	visible := map[string]revisionSlotResponse{"main": {ContentModel: ContentModelWikitext, Content: &content}}
+	hidden := map[string]revisionSlotResponse{"main": {ContentModel: ContentModelWikitext, TextHidden: true}}
+
+	tests := []struct {
+		name       string
+		response   revisionResponse
+		userHidden bool
+		err        error
+	}{
+		{"visible", revisionResponse{RevId: 1, User: "Example", Slots: visible}, false, nil},
+		{"user hidden", revisionResponse{RevId: 1, UserHidden: true, Slots: visible}, true, nil},
+		{"text hidden", revisionResponse{RevId: 1, User: "Example", Slots: hidden}, false, ErrTextHidden},
+		{"text suppressed", revisionResponse{RevId: 1, User: "Example", Suppressed: true, Slots: hidden}, false, ErrSuppressed},
+		{"no main slot", revisionResponse{RevId: 1, User: "Example"}, false, ErrIncompleteData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.response.Timestamp = "2024-03-15T12:00:00Z"
+			revision, err := tt.response.toRevision(true)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if revision.UserHidden != tt.userHidden {
+				t.Errorf("expected user hidden %v, got %v", tt.userHidden, revision.UserHidden)
+			}
+			if err == nil && revision.Data != content {
+				t.Errorf("expected the content, got %q", revision.Data)
+			}
+		})
+	}
+}
+
+func TestEditBlockedBy(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	semi := Protection{Type: "edit", Level: "autoconfirmed", Expiry: "infinity"}
+	full := Protection{Type: "edit", Level: "sysop", Expiry: "infinity"}
+	expired := Protection{Type: "edit", Level: "sysop", Expiry: "2024-03-14T12:00:00Z"}
+	move := Protection{Type: "move", Level: "sysop", Expiry: "infinity"}
+
+	tests := []struct {
+		name       string
+		rights     []string
+		protection []Protection
+		blocked    bool
+	}{
+		{"unprotected", []string{"edit"}, []Protection{}, false},
+		{"semi protected", []string{"edit", "editsemiprotected"}, []Protection{semi}, false},
+		{"fully protected", []string{"edit", "editsemiprotected"}, []Protection{semi, full}, true},
+		{"expired protection", []string{"edit"}, []Protection{expired}, false},
+		{"move protection", []string{"edit"}, []Protection{move}, false},
+		{"unknown rights", nil, []Protection{full}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &WikipediaApi{rights: tt.rights}
+			if blocked := api.EditBlockedBy(tt.protection, now); (blocked != nil) != tt.blocked {
+				t.Errorf("expected blocked %v, got %+v", tt.blocked, blocked)
+			}
+		})
+	}
+}
+
+func TestTitleBlacklistedIsProtected(t *testing.T) {
+	err := error(&ApiError{Code: "titleblacklist-forbidden"})
+	if !errors.Is(err, ErrTitleBlacklisted) || !errors.Is(err, ErrProtected) {
+		t.Errorf("expected %v to match the title blacklist and protection", err)
+	}
+	if errors.Is(&ApiError{Code: "protectedpage"}, ErrTitleBlacklisted) {
+		t.Errorf("expected page protection not to match the title blacklist")
+	}
+}
+
+func TestBackoffCapsRetryAfter(t *testing.T) {
+	for _, retryAfter := range []time.Duration{0, 10 * time.Second, 2 * time.Hour} {
+		if delay := backoff(3, retryAfter); delay > maxBackoff+maxBackoff/2 {
+			t.Errorf("retry after %s: expected at most %s, got %s", retryAfter, maxBackoff+maxBackoff/2, delay)
+		}
+	}
+	if delay := backoff(1, 10*time.Second); delay < 10*time.Second {
+		t.Errorf("expected Retry-After to be honoured, got %s", delay)
+	}
+}
+
+func TestCheckThrottleRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		reason  string
+		refused bool
+	}{
+		{"ok", http.StatusOK, `{"query": {}}`, "", false},
+		{"http 429", http.StatusTooManyRequests, ``, "http_429", false},
+		{"http 503", http.StatusServiceUnavailable, ``, "http_503", false},
+		{"maxlag", http.StatusOK, `{"error": {"code": "maxlag", "lag": 6}}`, "maxlag", true},
+		{"ratelimited", http.StatusOK, `{"error": {"code": "ratelimited"}}`, "ratelimited", true},
+		{"other error", http.StatusOK, `{"error": {"code": "badtoken"}}`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := checkThrottle(&http.Response{StatusCode: tt.status, Header: http.Header{}}, []byte(tt.body))
+			if tt.reason == "" {
+				if throttle != nil {
+					t.Errorf("expected no throttle, got %+v", throttle)
+				}
+				return
+			}
+			if throttle == nil || throttle.reason != tt.reason || throttle.refused != tt.refused {
+				t.Errorf("expected %s (refused %v), got %+v", tt.reason, tt.refused, throttle)
+			}
+		})
+	}
+}
+
+// Trimmed from a real action=compare&prop=diff response
+const compareBody = `<tr>
+  <td colspan="2" class="diff-lineno">Line 1:</td>
+  <td colspan="2" class="diff-lineno">Line 1:</td>
+</tr>
+<tr>
+  <td class="diff-marker" data-marker="−"></td>
+  <td class="diff-deletedline diff-side-deleted"><div>The cat sat on the <del class="diffchange diffchange-inline">mat</del>.</div></td>
+  <td class="diff-marker" data-marker="+"></td>
+  <td class="diff-addedline diff-side-added"><div>The cat sat on the <ins class="diffchange diffchange-inline">dog &amp; ate it</ins>.</div></td>
+</tr>
+<tr>
+  <td class="diff-marker"></td>
+  <td class="diff-context diff-side-deleted"><div>[[Category:Cats]]</div></td>
+  <td class="diff-marker"></td>
+  <td class="diff-context diff-side-added"><div>[[Category:Cats]]</div></td>
+</tr>
+<tr>
+  <td colspan="2" class="diff-empty diff-side-deleted"></td>
+  <td class="diff-marker" data-marker="+"></td>
+  <td class="diff-addedline diff-side-added"><div>&lt;ref&gt;New &quot;source&quot;&lt;/ref&gt;</div></td>
+</tr>
+<tr>
+  <td class="diff-marker" data-marker="−"></td>
+  <td class="diff-deletedline diff-side-deleted"><div>Removed paragraph</div></td>
+  <td colspan="2" class="diff-empty diff-side-added"></td>
+</tr>
+<tr>
+  <td colspan="2" class="diff-empty diff-side-deleted"></td>
+  <td class="diff-marker" data-marker="+"></td>
+  <td class="diff-addedline diff-side-added"><br /></td>
+</tr>`
+
+func TestParseDiff(t *testing.T) {
+	diff := ParseDiff(compareBody)
+
+	expected := Diff{
+		AddedLines:   []string{"The cat sat on the dog & ate it.", `<ref>New "source"</ref>`, ""},
+		RemovedLines: []string{"The cat sat on the mat.", "Removed paragraph"},
+		Added:        []string{"dog & ate it", `<ref>New "source"</ref>`, ""},
+		Removed:      []string{"mat", "Removed paragraph"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diff)
+	}
+}
+
+func TestParseDiffMultipleInlineChanges(t *testing.T) {
+	body := `<tr>
+  <td class="diff-marker" data-marker="−"></td>
+  <td class="diff-deletedline diff-side-deleted"><div><del class="diffchange diffchange-inline">One</del> two <del class="diffchange diffchange-inline">three</del></div></td>
+  <td class="diff-marker" data-marker="+"></td>
+  <td class="diff-addedline diff-side-added"><div><ins class="diffchange diffchange-inline">1</ins> two <ins class="diffchange diffchange-inline">3</ins></div></td>
+</tr>`
+
+	diff := ParseDiff(body)
+	if !reflect.DeepEqual(diff.Added, []string{"1", "3"}) || !reflect.DeepEqual(diff.Removed, []string{"One", "three"}) {
+		t.Errorf("expected only the inline changes, got +%v -%v", diff.Added, diff.Removed)
+	}
+	if !reflect.DeepEqual(diff.AddedLines, []string{"1 two 3"}) || !reflect.DeepEqual(diff.RemovedLines, []string{"One two three"}) {
+		t.Errorf("expected the whole changed lines, got +%v -%v", diff.AddedLines, diff.RemovedLines)
+	}
+}
+
+func TestParseDiffUnchanged(t *testing.T) {
+	body := `<tr>
+  <td class="diff-marker"></td>
+  <td class="diff-context diff-side-deleted"><div>Same</div></td>
+  <td class="diff-marker"></td>
+  <td class="diff-context diff-side-added"><div>Same</div></td>
+</tr>`
+
+	if diff := ParseDiff(body); len(diff.AddedLines) != 0 || len(diff.RemovedLines) != 0 {
+		t.Errorf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestSitewideBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		blockId  int64
+	}{
+		{"not blocked", `{"blocks":[]}`, 0},
+		{"sitewide", `{"blocks":[{"id":1,"user":"Vandal","by":"Admin","timestamp":"2026-01-02T03:04:05Z","expiry":"infinity","reason":"Vandalism","restrictions":[]}]}`, 1},
+		{"partial only", `{"blocks":[{"id":2,"user":"Vandal","by":"Admin","timestamp":"2026-01-02T03:04:05Z","expiry":"infinity","reason":"Edit warring","partial":true,"restrictions":{"pages":[{"id":1,"ns":0,"title":"Example"}]}}]}`, 0},
+		{"partial then range", `{"blocks":[` +
+			`{"id":3,"user":"192.0.2.1","by":"Admin","timestamp":"2026-01-02T03:04:05Z","expiry":"infinity","reason":"Edit warring","partial":true,"restrictions":{"namespaces":[0]}},` +
+			`{"id":4,"user":"192.0.2.0/24","by":"Admin","timestamp":"2026-01-02T03:04:05Z","expiry":"2026-02-02T03:04:05Z","reason":"Range block","rangestart":"192.0.2.0","rangeend":"192.0.2.255","restrictions":[]}` +
+			`]}`, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := struct {
+				Blocks []blockEntry `json:"blocks"`
+			}{}
+			if err := json.Unmarshal([]byte(tt.response), &data); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			block := sitewideBlock(data.Blocks)
+			if tt.blockId == 0 {
+				if block != nil {
+					t.Errorf("expected no sitewide block, got %+v", block)
+				}
+				return
+			}
+			if block == nil || block.Id != tt.blockId {
+				t.Fatalf("expected block %d, got %+v", tt.blockId, block)
+			}
+			if block.Timestamp != time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Unix() {
+				t.Errorf("unexpected timestamp %d", block.Timestamp)
+			}
+		})
+	}
+}